@@ -36,6 +36,7 @@ type AspellLayout struct {
 	source          tui.TextBufferView
 	dock            tui.Dock
 	statusBar       tui.StatusBar
+	fileName        string
 	suggestionCount int
 }
 
@@ -46,7 +47,12 @@ func (self *AspellLayout) Configure(cfg *Config) {
 
 func (self *AspellLayout) SetSource(sf *SourceFile) {
 	self.source.SetTextBuffer(sf.Text())
-	self.statusBar.SetLeft(filepath.Clean(sf.Name()))
+	self.fileName = filepath.Clean(sf.Name())
+	self.statusBar.SetLeft(self.fileName)
+}
+
+func (self *AspellLayout) SetMessage(message string) {
+	self.statusBar.SetLeft(statusText(self.fileName, message))
 }
 
 func (self *AspellLayout) Show(index tui.SliceIndex) {
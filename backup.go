@@ -84,6 +84,14 @@ func (self *Backup) AddLine(line int, tb *tui.TextBuffer, replacements map[tui.S
 	self.lastLine = line
 }
 
+// AddText adds a line with the given original text.
+func (self *Backup) AddText(line int, text string) {
+	self.lines = append(self.lines, backupLine{
+		line: line,
+		text: text,
+	})
+}
+
 // Create creates the backup file
 func (self *Backup) Create() error {
 	var err error
@@ -9,31 +9,85 @@ import (
 type styleData struct {
 	name        string
 	extenstions []string
+	filenames   []string
+	shebangs    []string
 	style       common.CommentStyle
 }
 
-// Note: there is no special support for character literals.
 var defaultStringStyle = []common.StringStyle{
 	{Begin: "\"", End: "\"", Escape: "\\\""},
 	{Begin: "'", End: "'", Escape: "\\'"},
 }
 
+var doubleQuoteStringStyle = []common.StringStyle{
+	{Begin: "\"", End: "\"", Escape: "\\\""},
+}
+
+// Doxygen, Javadoc, JSDoc, and similar doc comments.
+var (
+	slashDocLine       = []string{"///", "//!"}
+	slashDocBlockBegin = []string{"/**", "/*!"}
+	slashDocBlockEnd   = []string{"*/", "*/"}
+)
+
+var defaultCharStyle = []common.CharStyle{
+	{Quote: "'", Escape: "\\"},
+}
+
 // Source: https://en.wikipedia.org/wiki/Comparison_of_programming_languages_(syntax)#Comments
 var builtinStyles = []styleData{
 	{
 		name: "builtin-c",
 		extenstions: []string{
 			"c", "cc", "cpp", "cxx", "h", "hh", "hpp", "hxx",
-			"go",
-			"js",
+			"js", "mjs", "cjs", "jsx", "ts", "tsx",
 			"cs",
 			"java",
+			"dart",
+			"groovy", "gradle",
+			"scala",
+			"proto",
 		},
+		shebangs: []string{"node", "deno"},
 		style: common.CommentStyle{
 			Line:       []string{"//"},
 			BlockBegin: []string{"/*"},
 			BlockEnd:   []string{"*/"},
-			Strings:    defaultStringStyle,
+			Strings: append([]common.StringStyle{
+				// C++ raw strings: R"delim(...)delim"
+				{Begin: `(?:u8|[uUL])?R"([^()\\\s]{0,16})\(`, End: `)${1}"`, Regex: true},
+			}, defaultStringStyle...),
+			// Multi-character constants like 'abcd' are valid in C.
+			Chars: []common.CharStyle{
+				{Quote: "'", Escape: "\\", MaxLength: 4},
+			},
+			SkipRegions: []common.SkipRegion{
+				{
+					// Only a literal 0, optionally followed by a comment.
+					Begin: `^#[ \t]*if[ \t]+0[ \t]*(?:(?m:$)|//|/\*)`,
+					End:   []string{`^#[ \t]*endif\b`, `^#[ \t]*else\b`, `^#[ \t]*elif\b`},
+					Nest:  `^#[ \t]*if`,
+					Regex: true,
+				},
+			},
+			DocLine:       slashDocLine,
+			DocBlockBegin: slashDocBlockBegin,
+			DocBlockEnd:   slashDocBlockEnd,
+		},
+	},
+	{
+		name:        "builtin-go",
+		extenstions: []string{"go"},
+		style: common.CommentStyle{
+			Line:       []string{"//"},
+			BlockBegin: []string{"/*"},
+			BlockEnd:   []string{"*/"},
+			Strings: []common.StringStyle{
+				{Begin: "\"", End: "\"", Escape: "\\\""},
+				{Begin: "`", End: "`"},
+			},
+			Chars:     defaultCharStyle,
+			DocBefore: []string{"package", "func", "type", "var", "const"},
 		},
 	},
 	{
@@ -44,64 +98,507 @@ var builtinStyles = []styleData{
 			BlockBegin:   []string{"/*"},
 			BlockEnd:     []string{"*/"},
 			BlockNesting: true,
-			// We cannot match character literals as the ' also appears in lifetimes
 			Strings: []common.StringStyle{
-				// Add a special case for '"' so we don't match the string begin
-				// inside that. This must be before the other kind as their ends
-				// overlap.
-				{Begin: "'\"", End: "'"},
 				{Begin: "\"", End: "\"", Escape: "\\\""},
-				{Begin: "r#\"", End: "\"#"},
+				{Begin: `[bc]?r(#*)"`, End: `"${1}`, Regex: true},
 			},
+			// The ' also appears in lifetimes but these are never closed.
+			Chars:         defaultCharStyle,
+			DocLine:       slashDocLine,
+			DocBlockBegin: slashDocBlockBegin,
+			DocBlockEnd:   slashDocBlockEnd,
 		},
 	},
 	{
 		name:        "builtin-python",
-		extenstions: []string{"py"},
+		extenstions: []string{"py", "pyi", "pyw"},
+		shebangs:    []string{"python"},
 		style: common.CommentStyle{
 			Line:       []string{"#"},
 			BlockBegin: []string{"\"\"\"", "'''"},
 			BlockEnd:   []string{"\"\"\"", "'''"},
-			// We cannot support strings with the current parser as we'd always
-			// match the strings over the doc strings.
-			// Luckily we can only wrongly identify line comments so we don't
-			// need to worry about unbalanced block comment tokens.
+			// The doc string tokens are longer than the string tokens so they
+			// take precedence.
+			Strings: append([]common.StringStyle{
+				// Prefixed strings like f"..." or rb'''...'''
+				{Begin: `(?i)[rbfut]{1,2}("""|''')`, End: "${1}", Regex: true},
+				{Begin: `(?i)[rbfut]{1,2}(["'])`, End: "${1}", Escape: "\\${1}", Regex: true},
+			}, defaultStringStyle...),
+			DocStrings: true,
 		},
 	},
 	{
-		name:        "builtin-#",
-		extenstions: []string{"sh", "bashrc", "toml", "ini", "cfg", "rb"},
+		name:        "builtin-sh",
+		extenstions: []string{"sh", "bash", "zsh", "ksh", "bashrc"},
+		filenames:   []string{".bash_profile", ".bashrc", ".profile", ".zshrc"},
+		shebangs:    []string{"sh", "bash", "zsh", "dash", "ksh"},
+		style: common.CommentStyle{
+			Line: []string{"#"},
+			Strings: append([]common.StringStyle{
+				// Arithmetic expressions, so shifts are not taken as heredocs.
+				{Begin: "$((", End: "))"},
+				{Begin: "((", End: "))"},
+				// Here-strings, so their last two `<` are not taken as a
+				// heredoc. Like with heredocs the rest of the line is part of
+				// the string.
+				{Begin: "<<<", End: "\n"},
+				// Heredocs, the delimiter must be on its own line. Note that
+				// the rest of the line with the operator is part of the string
+				// as well.
+				{Begin: `<<[-~]?[ \t]*["']?([A-Za-z_]\w*)["']?`, End: "^${1}\n", Regex: true},
+			}, defaultStringStyle...),
+		},
+	},
+	{
+		name: "builtin-#",
+		extenstions: []string{
+			"toml", "ini", "cfg", "conf",
+			"rb", "pl", "pm",
+			"cmake",
+		},
+		filenames: []string{"CMakeLists.txt", "Gemfile", "Rakefile"},
+		shebangs:  []string{"ruby", "perl"},
 		style: common.CommentStyle{
 			Line:    []string{"#"},
 			Strings: defaultStringStyle,
 		},
 	},
+	{
+		name:        "builtin-lua",
+		extenstions: []string{"lua"},
+		shebangs:    []string{"lua", "luajit"},
+		style: common.CommentStyle{
+			Line:       []string{"--"},
+			BlockBegin: []string{`--\[(=*)\[`},
+			BlockEnd:   []string{"]${1}]"},
+			BlockRegex: true,
+			Strings: append([]common.StringStyle{
+				{Begin: `\[(=*)\[`, End: "]${1}]", Regex: true},
+			}, defaultStringStyle...),
+			DocLine: []string{"---"},
+		},
+	},
+	{
+		name:        "builtin-sql",
+		extenstions: []string{"sql"},
+		style: common.CommentStyle{
+			Line:       []string{"--"},
+			BlockBegin: []string{"/*"},
+			BlockEnd:   []string{"*/"},
+			// Quotes are escaped by doubling them which needs no special
+			// handling as it just looks like two adjacent strings.
+			Strings: []common.StringStyle{
+				{Begin: "'", End: "'"},
+				{Begin: "\"", End: "\""},
+			},
+		},
+	},
+	{
+		name:        "builtin-haskell",
+		extenstions: []string{"hs", "elm", "purs"},
+		shebangs:    []string{"runghc", "runhaskell"},
+		style: common.CommentStyle{
+			Line:         []string{"--"},
+			BlockBegin:   []string{"{-"},
+			BlockEnd:     []string{"-}"},
+			BlockNesting: true,
+			Strings:      doubleQuoteStringStyle,
+			// The ' also appears in identifiers.
+			Chars:         defaultCharStyle,
+			DocLine:       []string{"-- |", "-- ^"},
+			DocBlockBegin: []string{"{-|"},
+			DocBlockEnd:   []string{"-}"},
+		},
+	},
+	{
+		name:        "builtin-ocaml",
+		extenstions: []string{"ml", "mli", "mll", "mly"},
+		shebangs:    []string{"ocaml"},
+		style: common.CommentStyle{
+			BlockBegin:   []string{"(*"},
+			BlockEnd:     []string{"*)"},
+			BlockNesting: true,
+			Strings:      doubleQuoteStringStyle,
+			// The ' also appears in identifiers and type variables.
+			Chars:         defaultCharStyle,
+			DocBlockBegin: []string{"(**"},
+			DocBlockEnd:   []string{"*)"},
+		},
+	},
+	{
+		name:        "builtin-fsharp",
+		extenstions: []string{"fs", "fsi", "fsx"},
+		style: common.CommentStyle{
+			Line:         []string{"//"},
+			BlockBegin:   []string{"(*"},
+			BlockEnd:     []string{"*)"},
+			BlockNesting: true,
+			Strings: []common.StringStyle{
+				{Begin: "\"\"\"", End: "\"\"\""},
+				{Begin: "@\"", End: "\""},
+				{Begin: "\"", End: "\"", Escape: "\\\""},
+			},
+			Chars:   defaultCharStyle,
+			DocLine: []string{"///"},
+		},
+	},
+	{
+		name: "builtin-lisp",
+		extenstions: []string{
+			"lisp", "lsp", "cl", "el",
+			"scm", "ss", "rkt",
+			"clj", "cljs", "cljc", "edn",
+			"fnl",
+		},
+		shebangs: []string{"sbcl", "racket", "guile", "clojure"},
+		style: common.CommentStyle{
+			Line:         []string{";"},
+			BlockBegin:   []string{"#|"},
+			BlockEnd:     []string{"|#"},
+			BlockNesting: true,
+			// The ' is used for quoting.
+			Strings: doubleQuoteStringStyle,
+		},
+	},
+	{
+		name:        "builtin-erlang",
+		extenstions: []string{"erl", "hrl"},
+		filenames:   []string{"rebar.config"},
+		shebangs:    []string{"escript"},
+		style: common.CommentStyle{
+			Line: []string{"%"},
+			// The ' is used for atoms.
+			Strings: doubleQuoteStringStyle,
+		},
+	},
+	{
+		name:        "builtin-elixir",
+		extenstions: []string{"ex", "exs"},
+		shebangs:    []string{"elixir"},
+		style: common.CommentStyle{
+			Line: []string{"#"},
+			Strings: []common.StringStyle{
+				{Begin: "\"\"\"", End: "\"\"\""},
+				{Begin: "'''", End: "'''"},
+				{Begin: "\"", End: "\"", Escape: "\\\""},
+				{Begin: "'", End: "'", Escape: "\\'"},
+			},
+		},
+	},
+	{
+		name: "builtin-html",
+		extenstions: []string{
+			"html", "htm", "xhtml",
+			"xml", "xsd", "xsl", "xslt", "svg", "plist",
+			// Single file components use the same sections.
+			"vue", "svelte",
+		},
+		// There are no strings as quotes also appear in normal text.
+		style: common.CommentStyle{
+			BlockBegin: []string{"<!--"},
+			BlockEnd:   []string{"-->"},
+			Embedded: []common.EmbeddedLanguage{
+				{Begin: `<style\b[^>\n]*\blang=["']?(scss|less)\b[^>\n]*>`, End: "</style>", Language: "${1}"},
+				{Begin: `<style\b[^>\n]*>`, End: "</style>", Language: "css"},
+				{Begin: `<script\b[^>\n]*\blang=["']?(ts|tsx|jsx)\b[^>\n]*>`, End: "</script>", Language: "${1}"},
+				{Begin: `<script\b[^>\n]*>`, End: "</script>", Language: "js"},
+			},
+		},
+	},
+	{
+		name:        "builtin-css",
+		extenstions: []string{"css"},
+		style: common.CommentStyle{
+			BlockBegin: []string{"/*"},
+			BlockEnd:   []string{"*/"},
+			Strings:    defaultStringStyle,
+		},
+	},
+	{
+		name:        "builtin-scss",
+		extenstions: []string{"scss", "less"},
+		style: common.CommentStyle{
+			Line:       []string{"//"},
+			BlockBegin: []string{"/*"},
+			BlockEnd:   []string{"*/"},
+			Strings:    defaultStringStyle,
+		},
+	},
+	{
+		name:        "builtin-php",
+		extenstions: []string{"php", "phtml"},
+		shebangs:    []string{"php"},
+		style: common.CommentStyle{
+			Line:          []string{"//", "#"},
+			BlockBegin:    []string{"/*"},
+			BlockEnd:      []string{"*/"},
+			Strings:       defaultStringStyle,
+			DocBlockBegin: []string{"/**"},
+			DocBlockEnd:   []string{"*/"},
+		},
+	},
+	{
+		name:        "builtin-swift",
+		extenstions: []string{"swift", "kt", "kts"},
+		shebangs:    []string{"swift", "kotlin"},
+		style: common.CommentStyle{
+			Line:         []string{"//"},
+			BlockBegin:   []string{"/*"},
+			BlockEnd:     []string{"*/"},
+			BlockNesting: true,
+			Strings: []common.StringStyle{
+				{Begin: "\"\"\"", End: "\"\"\""},
+				{Begin: "\"", End: "\"", Escape: "\\\""},
+			},
+			DocLine:       []string{"///"},
+			DocBlockBegin: []string{"/**"},
+			DocBlockEnd:   []string{"*/"},
+		},
+	},
+	{
+		name:        "builtin-zig",
+		extenstions: []string{"zig", "zon"},
+		style: common.CommentStyle{
+			Line: []string{"//"},
+			Strings: []common.StringStyle{
+				// Multiline string literals
+				{Begin: "\\\\", End: "\n"},
+				{Begin: "\"", End: "\"", Escape: "\\\""},
+			},
+			Chars:   defaultCharStyle,
+			DocLine: []string{"///", "//!"},
+		},
+	},
+	{
+		name:        "builtin-nim",
+		extenstions: []string{"nim", "nims", "nimble"},
+		style: common.CommentStyle{
+			Line:         []string{"#"},
+			BlockBegin:   []string{"#["},
+			BlockEnd:     []string{"]#"},
+			BlockNesting: true,
+			Strings: []common.StringStyle{
+				{Begin: "\"\"\"", End: "\"\"\""},
+				{Begin: "\"", End: "\"", Escape: "\\\""},
+			},
+			// The ' is also used for type suffixes of numbers.
+			Chars:         defaultCharStyle,
+			DocLine:       []string{"##"},
+			DocBlockBegin: []string{"##["},
+			DocBlockEnd:   []string{"]##"},
+		},
+	},
+	{
+		name:        "builtin-r",
+		extenstions: []string{"r", "R"},
+		shebangs:    []string{"Rscript"},
+		style: common.CommentStyle{
+			Line:    []string{"#"},
+			Strings: defaultStringStyle,
+			// Roxygen comments
+			DocLine: []string{"#'"},
+		},
+	},
+	{
+		name:        "builtin-matlab",
+		extenstions: []string{"m"},
+		shebangs:    []string{"octave"},
+		style: common.CommentStyle{
+			Line:       []string{"%"},
+			BlockBegin: []string{"%{"},
+			BlockEnd:   []string{"%}"},
+			// The ' is also the transpose operator.
+			Strings: []common.StringStyle{
+				{Begin: "\"", End: "\""},
+			},
+		},
+	},
+	{
+		// Only free form, fixed form comments begin with a C or * in the first
+		// column, which can't be told apart from indented statements.
+		name:        "builtin-fortran",
+		extenstions: []string{"f90", "f95", "f03", "f08", "F90"},
+		style: common.CommentStyle{
+			Line: []string{"!"},
+			// Quotes are escaped by doubling them.
+			Strings: []common.StringStyle{
+				{Begin: "'", End: "'"},
+				{Begin: "\"", End: "\""},
+			},
+		},
+	},
+	{
+		name:        "builtin-vhdl",
+		extenstions: []string{"vhd", "vhdl"},
+		style: common.CommentStyle{
+			Line:       []string{"--"},
+			BlockBegin: []string{"/*"},
+			BlockEnd:   []string{"*/"},
+			Strings: []common.StringStyle{
+				{Begin: "\"", End: "\""},
+			},
+			// The ' is also used for attributes.
+			Chars: []common.CharStyle{{Quote: "'"}},
+		},
+	},
+	{
+		name:        "builtin-verilog",
+		extenstions: []string{"v", "vh", "sv", "svh"},
+		style: common.CommentStyle{
+			Line:       []string{"//"},
+			BlockBegin: []string{"/*"},
+			BlockEnd:   []string{"*/"},
+			// The ' is also used in number literals.
+			Strings: doubleQuoteStringStyle,
+		},
+	},
+	{
+		name:        "builtin-yaml",
+		extenstions: []string{"yml", "yaml"},
+		filenames:   []string{".clang-format", ".clang-tidy"},
+		style: common.CommentStyle{
+			Line: []string{"#"},
+			// The ' also appears in plain scalars.
+			Strings: doubleQuoteStringStyle,
+		},
+	},
+	{
+		name:        "builtin-dockerfile",
+		extenstions: []string{"dockerfile"},
+		filenames: []string{
+			"Dockerfile", "Dockerfile.*",
+			"Containerfile", "Containerfile.*",
+		},
+		style: common.CommentStyle{
+			Line:    []string{"#"},
+			Strings: defaultStringStyle,
+		},
+	},
+	{
+		name:        "builtin-makefile",
+		extenstions: []string{"mk", "mak", "make"},
+		filenames:   []string{"Makefile", "GNUmakefile", "makefile"},
+		shebangs:    []string{"make"},
+		style: common.CommentStyle{
+			Line:    []string{"#"},
+			Strings: defaultStringStyle,
+		},
+	},
+	{
+		name:        "builtin-asm",
+		extenstions: []string{"asm", "nasm", "s", "S"},
+		// Covers both the Intel (NASM, MASM) and AT&T (GAS) syntax.
+		style: common.CommentStyle{
+			Line:       []string{";", "#"},
+			BlockBegin: []string{"/*"},
+			BlockEnd:   []string{"*/"},
+			Strings:    defaultStringStyle,
+		},
+	},
+	{
+		name:        "builtin-powershell",
+		extenstions: []string{"ps1", "psm1", "psd1"},
+		shebangs:    []string{"pwsh"},
+		style: common.CommentStyle{
+			Line:       []string{"#"},
+			BlockBegin: []string{"<#"},
+			BlockEnd:   []string{"#>"},
+			Strings: []common.StringStyle{
+				// Here-strings
+				{Begin: "@\"", End: "\"@"},
+				{Begin: "@'", End: "'@"},
+				{Begin: "\"", End: "\"", Escape: "`\""},
+				{Begin: "'", End: "'"},
+			},
+		},
+	},
+	{
+		name:        "builtin-markdown",
+		extenstions: []string{"md", "markdown", "mdown", "mkd"},
+		// Code spans and fenced code blocks are skipped like in comments,
+		// unless the block has a language.
+		style: common.CommentStyle{
+			BlockBegin:  []string{"<!--"},
+			BlockEnd:    []string{"-->"},
+			Prose:       true,
+			FrontMatter: []string{"---", "+++"},
+			Embedded: []common.EmbeddedLanguage{
+				{Begin: "(```+|~~~+)[ \\t]*\\{?\\.?([\\w+#-]+)", End: "^${1}", Language: "${2}"},
+			},
+		},
+	},
+	{
+		name:        "builtin-rst",
+		extenstions: []string{"rst", "rest"},
+		style: common.CommentStyle{
+			Prose: true,
+		},
+	},
+	{
+		name:        "builtin-notebook",
+		extenstions: []string{"ipynb"},
+		style: common.CommentStyle{
+			Notebook: true,
+		},
+	},
+	{
+		name:        "builtin-text",
+		extenstions: []string{"txt", "text"},
+		style: common.CommentStyle{
+			Prose: true,
+		},
+	},
+	{
+		// Git commit messages, the diff added by `git commit -v` follows the
+		// scissors line.
+		name:      "builtin-git-commit",
+		filenames: []string{"COMMIT_EDITMSG", "MERGE_MSG", "TAG_EDITMSG", "SQUASH_MSG"},
+		style: common.CommentStyle{
+			Prose:  true,
+			Line:   []string{"^#"},
+			CutOff: []string{"^# ------------------------ >8 ------------------------"},
+		},
+	},
 }
 
 // MergeBuiltinStyles merges the builtin styles into the given config.
-// Extensions that are already set are removed, if a builtin style has no
-// unset extensions it is skipped.
+// Extensions, file names, and shebangs that are already set are removed, if a
+// builtin style has none of them left it is skipped.
 func MergeBuiltinStyles(cfg *common.Config) {
-	set := map[string]bool{}
 	caser := cases.Fold()
-	for _, extensions := range cfg.Extensions {
-		for _, ext := range extensions {
-			set[caser.String(ext)] = true
+	makeSet := func(table map[string][]string) map[string]bool {
+		set := map[string]bool{}
+		for _, values := range table {
+			for _, value := range values {
+				set[caser.String(value)] = true
+			}
 		}
+		return set
 	}
-	for _, style := range builtinStyles {
-		// Additional extensions for builtin types may be specified in the config.
-		predef := cfg.Extensions[style.name]
-		extensions := util.Filter(style.extenstions, func(ext string) bool {
-			return !set[ext]
+	// merge returns the builtin values that are not set yet, followed by the
+	// values the config specifies for the builtin style.
+	merge := func(set map[string]bool, builtin, predef []string) []string {
+		values := util.Filter(util.Copy(builtin), func(value string) bool {
+			return !set[caser.String(value)]
 		})
-		// We don't need to de-duplicate as predefined extensions are in the set
-		// and got filtered from our extensions.
-		extensions = append(extensions, predef...)
-		if len(extensions) == 0 {
+		// We don't need to de-duplicate as predefined values are in the set
+		// and got filtered from our values.
+		return append(values, predef...)
+	}
+	extensionSet := makeSet(cfg.Extensions)
+	filenameSet := makeSet(cfg.Filenames)
+	shebangSet := makeSet(cfg.Shebangs)
+	for _, style := range builtinStyles {
+		// Additional values for builtin types may be specified in the config.
+		extensions := merge(extensionSet, style.extenstions, cfg.Extensions[style.name])
+		filenames := merge(filenameSet, style.filenames, cfg.Filenames[style.name])
+		shebangs := merge(shebangSet, style.shebangs, cfg.Shebangs[style.name])
+		if len(extensions) == 0 && len(filenames) == 0 && len(shebangs) == 0 {
 			continue
 		}
 		cfg.Styles[style.name] = style.style
 		cfg.Extensions[style.name] = extensions
+		cfg.Filenames[style.name] = filenames
+		cfg.Shebangs[style.name] = shebangs
 	}
 }
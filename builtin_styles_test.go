@@ -0,0 +1,197 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/parser"
+)
+
+func findBuiltinStyle(name string) common.CommentStyle {
+	for _, style := range builtinStyles {
+		if style.name == name {
+			return style.style
+		}
+	}
+	panic("no builtin style named " + name)
+}
+
+// commentWords returns the text of all comment words in source. If docOnly is
+// set only words in doc comments are returned.
+func commentWords(source string, style common.CommentStyle, docOnly ...bool) []string {
+	cfg := common.DefaultConfig()
+	MergeBuiltinStyles(&cfg)
+	lexer := parser.NewLexer(source, style)
+	lexer.SetLanguages(func(language string) common.CommentStyle {
+		if name := cfg.LanguageStyle(language); name.IsSome() {
+			return cfg.Styles[name.Unwrap()]
+		}
+		return common.CommentStyle{}
+	})
+	words := []string{}
+	inDoc := false
+	for {
+		token := lexer.Next()
+		switch token.Kind() {
+		case parser.TokenKind.CommentBegin:
+			inDoc = token.IsDoc()
+		case parser.TokenKind.CommentWord:
+			if len(docOnly) == 0 || inDoc {
+				words = append(words, token.Text())
+			}
+		case parser.TokenKind.EOF:
+			return words
+		}
+	}
+}
+
+func TestBuiltinStyles(t *testing.T) {
+	tests := []struct {
+		style  string
+		source string
+		words  []string
+	}{
+		{"builtin-c", "x = \"// no\"; // one\n/* two */", []string{"one", "two"}},
+		{"builtin-c", "x = '\"'; y = '/' /'*'/ '\\'' // one\nz = '\\\\'; // two", []string{"one", "two"}},
+		{"builtin-c", "#if 0\n// no\n#ifdef X\n#else\n/* no */\n#endif\n#elif 1\n// one\n#endif", []string{"one"}},
+		{"builtin-c", "#if 0x1\n// one\n#endif\n#if 01 // two\n#endif\n#if 0 || X\n// three\n#endif", []string{"one", "two", "three"}},
+		{"builtin-c", "  #  if 0 // no\n// no\n#  endif // one", []string{"one"}},
+		{"builtin-c", "#if 0\n// see #if below\n#endif\n// one", []string{"one"}},
+		{"builtin-c", "R\"x(// no)\" )x\" // one\nu8R\"(\"// no)\" // two", []string{"one", "two"}},
+		{"builtin-rust", "'\"' /* one /* two */ three */ r#\"// no\"#", []string{"one", "two", "three"}},
+		{"builtin-rust", "fn f<'a, 'b>(x: &'a str) -> char { '\"' } // one\nlet c = '\\u{1F600}'; // two", []string{"one", "two"}},
+		{"builtin-rust", "r##\"\"# // no\"## // one\nbr\"\\\" // two", []string{"one", "two"}},
+		{"builtin-python", "f'{x!r} # no' # one\nrb\"\"\"\n# no\n\"\"\" # two\nF\"\\\" # no\" # three", []string{"one", "two", "three"}},
+		{"builtin-python", "x = '# no' # one\n\"\"\"two\"\"\"\n''' three '''", []string{"one", "two", "three"}},
+		{"builtin-sh", "echo \"# no\" # one", []string{"one"}},
+		{"builtin-sh", "cat <<-'EOF'\n# no\nEOFX # no\n\tEOF\necho $((1<<2)) # one", []string{"one"}},
+		{"builtin-sh", "echo $((1<<x)) # one\n(( n <<= bits )) # two\necho $(( 16#ff )) # three", []string{"one", "two", "three"}},
+		{"builtin-sh", "cat <<< \"$x\" # no\n# one\ncat<<EOF\n# no\nEOF\n# two", []string{"one", "two"}},
+		{"builtin-#", "puts \"# no\" # one", []string{"one"}},
+		{"builtin-lua", "x = '-- no' -- one\n--[[ two ]] --[==[ three ]] four ]==] s = [[ -- no ]]", []string{"one", "two", "three", "four"}},
+		{"builtin-lua", "s = [==========[ ]] -- no ]==========] --[=======[ one ]=======]", []string{"one"}},
+		{"builtin-sql", "SELECT '-- no', \"it''s\" -- one\n/* two */", []string{"one", "two"}},
+		{"builtin-haskell", "x' = \"-- no\" -- one\n{- two {- three -} four -}", []string{"one", "two", "three", "four"}},
+		{"builtin-haskell", "f' x' = ['\"', x'] -- one", []string{"one"}},
+		{"builtin-ocaml", "let x' = \"(* no *)\" (* one (* two *) three *)", []string{"one", "two", "three"}},
+		{"builtin-fsharp", "let x = @\"c:\\(* no\" // one\n(* two *) \"\"\"// no\"\"\"", []string{"one", "two"}},
+		{"builtin-lisp", "(print \"; no\") ; one\n#| two #| three |# |#", []string{"one", "two", "three"}},
+		{"builtin-erlang", "X = \"% no\", % one\n'atom'", []string{"one"}},
+		{"builtin-elixir", "x = \"# no\" # one\n\"\"\"\n# no\n\"\"\"", []string{"one"}},
+		{"builtin-html", "<p title=\"it's\">don't</p><!-- one --><!--\ntwo -->", []string{"one", "two"}},
+		{
+			"builtin-html",
+			"<script type=\"module\">\nlet x = '<!-- no -->'; // one\n</script> <!-- two -->\n<style>/* three */ a { content: \"<!--\" }</style><!-- four -->",
+			[]string{"one", "two", "three", "four"},
+		},
+		{"builtin-html", "<style lang=\"scss\">\n// one\n</style>\n<script>/* two </script> // no", []string{"one", "two"}},
+		{"builtin-html", "<template><!-- one --></template>\n<script lang=\"ts\">\nlet x: string = \"// no\" // two\n</script>", []string{"one", "two"}},
+		{"builtin-css", "a::after { content: \"/* no */\" } /* one */", []string{"one"}},
+		{"builtin-scss", "$x: '// no'; // one\n/* two */", []string{"one", "two"}},
+		{"builtin-php", "$x = '# no'; // one\n# two\n/* three */", []string{"one", "two", "three"}},
+		{"builtin-swift", "let x = \"\"\"\n// no\n\"\"\" /* one /* two */ */ // three", []string{"one", "two", "three"}},
+		{"builtin-zig", "const x = \\\\ // no\n; // one", []string{"one"}},
+		{"builtin-nim", "let x = \"# no\" # one\n#[ two #[ three ]# ]# ##[ four ]##", []string{"one", "two", "three", "four"}},
+		{"builtin-r", "x <- '# no' # one", []string{"one"}},
+		{"builtin-matlab", "x = a' % one\n%{\ntwo\n%}\ny = \"% no\"", []string{"one", "two"}},
+		{"builtin-fortran", "print *, 'it''s ! no' ! one", []string{"one"}},
+		{"builtin-vhdl", "x <= a'length; -- one\ns <= \"-- no\";", []string{"one"}},
+		{"builtin-verilog", "x = 4'b1010; // one\n$display(\"// no\"); /* two */", []string{"one", "two"}},
+		{"builtin-yaml", "key: \"# no\" # one\nother: it's", []string{"one"}},
+		{"builtin-dockerfile", "# one\nRUN echo \"# no\"", []string{"one"}},
+		{"builtin-makefile", "x := \"# no\" # one", []string{"one"}},
+		{"builtin-asm", "mov eax, ';' ; one\n# two\n/* three */", []string{"one", "two", "three"}},
+		{"builtin-powershell", "$x = \"`\"# no\" # one\n<# two #> @\"\n# no\n\"@", []string{"one", "two"}},
+		{"builtin-markdown", "---\ntitle: no\n---\n# One `no`\n\ntwo <!-- no -->\n```sh\nno\n```\nthree", []string{"One", "two", "three"}},
+		{"builtin-markdown", "+++\nno\n+++ one\n---\ntwo", []string{"one", "two"}},
+		{"builtin-markdown", "one\n```python\nx = '# no' # two\n  ```\nthree\n~~~{.c}\n// four\n~~~\n```json\nno\n```\nfive", []string{"one", "two", "three", "four", "five"}},
+		{"builtin-rst", "One\n===\n\nUse ``no`` and :func:`no`.", []string{"One", "Use", "and"}},
+		{"builtin-rst", ".. note:: no\n   no\n\n   no\none\n..\n   no\n\n.. no\ntwo... three", []string{"one", "two", "three"}},
+		{"builtin-rst", "One::\n\n    no\n\n      no\n\ntwo\n\n::\n\n  no\nthree::\nfour", []string{"One", "two", "three", "four"}},
+		{"builtin-text", "one\ntwo", []string{"one", "two"}},
+		{"builtin-git-commit", "one\n# no\ntwo #3\n# ------------------------ >8 ------------------------\ndiff no", []string{"one", "two"}},
+	}
+	for _, test := range tests {
+		words := commentWords(test.source, findBuiltinStyle(test.style))
+		if !reflect.DeepEqual(words, test.words) {
+			t.Errorf("%s: got %q, expected %q", test.style, words, test.words)
+		}
+	}
+}
+
+func TestBuiltinDocComments(t *testing.T) {
+	tests := []struct {
+		style  string
+		source string
+		words  []string
+	}{
+		{"builtin-c", "/// one\n// no\n/** two /**/ */ /* no */ //! three", []string{"one", "two", "three"}},
+		{"builtin-go", "// one\n// two\nfunc f() {} // no\n\n// no\n\nx := 1\n// no\nx = 2", []string{"one", "two"}},
+		{"builtin-go", "// one\npackage main\n/* no */\n\n\t// two\n\ttype T int", []string{"one", "two"}},
+		{"builtin-rust", "//! one\n/// two\n/** three /* four */ five */ // no", []string{"one", "two", "three", "four", "five"}},
+		{
+			"builtin-python",
+			"#!/usr/bin/python\n\"\"\"one\"\"\"\n@dec\nclass C:\n  '''two'''\n  x = '''no'''\n  async def f(\n    a: int,\n  ) -> int:\n    \"\"\"three\"\"\"\n    if x:\n      \"\"\"no\"\"\"",
+			[]string{"one", "two", "three"},
+		},
+		{"builtin-ocaml", "(**) (* no *) (** one *)", []string{"one"}},
+		{"builtin-nim", "## one\n# no\n##[ two ]##", []string{"one", "two"}},
+	}
+	for _, test := range tests {
+		words := commentWords(test.source, findBuiltinStyle(test.style), true)
+		if !reflect.DeepEqual(words, test.words) {
+			t.Errorf("%s: got %q, expected %q", test.style, words, test.words)
+		}
+	}
+}
+
+func TestBuiltinStylesAreDumped(t *testing.T) {
+	cfg := common.DefaultConfig()
+	MergeBuiltinStyles(&cfg)
+	for _, style := range builtinStyles {
+		if _, ok := cfg.Styles[style.name]; !ok {
+			t.Errorf("%s is missing from the config", style.name)
+		}
+	}
+}
+
+func TestCommitMessageCommentChar(t *testing.T) {
+	style := withCommentChar(findBuiltinStyle("builtin-git-commit"), ";")
+	source := "one\n; no\n# two\n; ------------------------ >8 ------------------------\ndiff no"
+	if got := commentWords(source, style); !reflect.DeepEqual(got, []string{"one", "two"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+// Same input as the lexer benchmark of the parser, so they show the cost of
+// the additional rules of the builtin style.
+func BenchmarkBuiltinC(b *testing.B) {
+	style := findBuiltinStyle("builtin-c")
+	source := strings.Repeat(
+		"int main() { // the main function\n\tputs(\"hello // world\"); /* a block\ncomment */\n}\n",
+		1000,
+	)
+	for i := 0; i < b.N; i++ {
+		lexer := parser.NewLexer(source, style)
+		for {
+			if token := lexer.Next(); token.Kind() == parser.TokenKind.EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestFortranFreeFormOnly(t *testing.T) {
+	cfg := common.DefaultConfig()
+	MergeBuiltinStyles(&cfg)
+	if style := cfg.DetectStyle("new.f90", "", ""); !style.IsSome() || style.Unwrap() != "builtin-fortran" {
+		t.Errorf("got %v for new.f90", style)
+	}
+	for _, name := range []string{"old.f", "old.for", "old.f77"} {
+		if style := cfg.DetectStyle(name, "", ""); style.IsSome() {
+			t.Errorf("got %q for the fixed form file %s", style.Unwrap(), name)
+		}
+	}
+}
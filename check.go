@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+	"github.com/JaMo42/spellcheck_comments/tui"
+)
+
+// wordColumn returns the 1-based column of a word in runes.
+func wordColumn(tb *tui.TextBuffer, index tui.SliceIndex) int {
+	column := 1
+	tb.ForEachInLine(index.Line(), func(s string, i tui.SliceIndex) {
+		if i.IsBefore(index) {
+			column += utf8.RuneCountInString(s)
+		}
+	})
+	return column
+}
+
+// checkFiles prints the misspelled words of the files without the interactive
+// interface, one per line in the `file:line:column: word` format. Returns
+// whether all files were OK.
+func checkFiles(sourceFiles chan sf.SourceFile) bool {
+	allOk := true
+	for source := range sourceFiles {
+		allOk = false
+		reportWords(os.Stdout, &source)
+	}
+	return allOk
+}
+
+// reportWords prints the misspelled words of a file to w.
+func reportWords(w io.Writer, source *sf.SourceFile) {
+	tb := source.Text()
+	for _, word := range source.Words() {
+		fmt.Fprintf(
+			w,
+			"%s:%d:%d: %s",
+			source.Name(),
+			word.Index.Line()+1,
+			wordColumn(tb, word.Index),
+			word.Original,
+		)
+		if len(word.Reason) != 0 {
+			fmt.Fprintf(w, " (%s)", word.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -2,28 +2,103 @@ package common
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+
+	"github.com/JaMo42/spellcheck_comments/util"
 )
 
 type StringStyle struct {
 	Begin  string `toml:"begin"`
 	End    string `toml:"end"`
 	Escape string `toml:"escape"`
+	// If set Begin is a regular expression and End and Escape may reference
+	// its groups.
+	Regex bool `toml:"regex"`
+}
+
+// CharStyle describes character literals. A quote is only treated as the
+// beginning of a character literal if it's closed within MaxLength characters,
+// so it can also be used on its own (i.e. lifetimes in Rust).
+type CharStyle struct {
+	Quote  string `toml:"quote"`
+	Escape string `toml:"escape"`
+	// The maximum number of characters in the literal, an escape sequence
+	// counts as 1 character. Defaults to 1.
+	MaxLength int `toml:"max-length"`
+}
+
+// SkipRegion describes a region of code that is ignored completely, including
+// comments inside it (i.e. `#if 0` blocks in C). Any of the end tokens ends the
+// region, without end tokens it continues to the end of the file. If Nest is
+// set it begins a nested region that must be closed first.
+type SkipRegion struct {
+	Begin string   `toml:"begin"`
+	End   []string `toml:"end"`
+	Nest  string   `toml:"nest"`
+	// If set Begin, End, and Nest are regular expressions, a leading `^` makes
+	// them only match at the beginning of a line.
+	Regex bool `toml:"regex"`
+}
+
+// EmbeddedLanguage describes a region of a file that uses another language,
+// like `<script>` in HTML. Begin is a regular expression, End and Language may
+// reference its groups. The language is resolved like languages in modelines.
+type EmbeddedLanguage struct {
+	Begin    string `toml:"begin"`
+	End      string `toml:"end"`
+	Language string `toml:"language"`
 }
 
 type CommentStyle struct {
-	Line         []string      `toml:"line"`
-	BlockBegin   []string      `toml:"block-begin"`
-	BlockEnd     []string      `toml:"block-end"`
-	BlockNesting bool          `toml:"block-nesting"`
-	Strings      []StringStyle `toml:"strings"`
+	Line         []string `toml:"line"`
+	BlockBegin   []string `toml:"block-begin"`
+	BlockEnd     []string `toml:"block-end"`
+	BlockNesting bool     `toml:"block-nesting"`
+	// If set BlockBegin are regular expressions and BlockEnd may reference
+	// their groups.
+	BlockRegex bool          `toml:"block-regex"`
+	Strings    []StringStyle `toml:"strings"`
+	Chars      []CharStyle   `toml:"chars"`
+	// Line comment tokens that begin doc comments.
+	DocLine []string `toml:"doc-line"`
+	// Block comment tokens that begin and end doc comments.
+	DocBlockBegin []string `toml:"doc-block-begin"`
+	DocBlockEnd   []string `toml:"doc-block-end"`
+	// Whether block comments are doc comments if they are the first statement
+	// in a module, function, or class (Python doc strings).
+	DocStrings bool `toml:"doc-strings"`
+	// Keywords of declarations that make the line comments directly above them
+	// doc comments.
+	DocBefore []string `toml:"doc-before"`
+	// Regions that are skipped, these can be turned off using the
+	// general.skip-regions option.
+	SkipRegions []SkipRegion `toml:"skip-regions"`
+	// If set the whole file is checked except for comments, strings, and skip
+	// regions (i.e. Markdown files).
+	Prose bool `toml:"prose"`
+	// Lines delimiting front matter at the beginning of the file.
+	FrontMatter []string `toml:"front-matter"`
+	// Lines after which the rest of the file is not checked (i.e. the
+	// scissors line in git commit messages). A leading `^` makes them only
+	// match at the beginning of a line.
+	CutOff []string `toml:"cut-off"`
+	// Regions of the file using other languages.
+	Embedded []EmbeddedLanguage `toml:"embedded"`
+	// If set the file is a Jupyter notebook, its cells are checked with the
+	// styles for Markdown and the notebook language and all other fields are
+	// ignored.
+	Notebook bool `toml:"notebook"`
 }
 
-func checkTokenLengths(tokens []string) error {
-	for _, tok := range tokens {
-		if len(tok) > 8 {
-			return fmt.Errorf("token longer than 8 bytes: %s", tok)
-		}
+// checkRegex checks that the pattern compiles and can be found by its prefix,
+// as the lexer does not try regexes at every character.
+func checkRegex(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return err
+	}
+	if util.RegexPrefixes(pattern) == nil {
+		return fmt.Errorf("regex %q must begin with fixed text or a character class", pattern)
 	}
 	return nil
 }
@@ -32,16 +107,68 @@ func (self *CommentStyle) Check() error {
 	if len(self.BlockBegin) != len(self.BlockEnd) {
 		return fmt.Errorf("multi-begin and multi-end values do not match")
 	}
-	for _, field := range [][]string{self.Line, self.BlockBegin, self.BlockEnd} {
-		if err := checkTokenLengths(field); err != nil {
+	if len(self.DocBlockBegin) != len(self.DocBlockEnd) {
+		return fmt.Errorf("doc-block-begin and doc-block-end values do not match")
+	}
+	if self.BlockRegex {
+		if self.BlockNesting {
+			return fmt.Errorf("block-nesting cannot be used with block-regex")
+		}
+		for _, begin := range self.BlockBegin {
+			if err := checkRegex(begin); err != nil {
+				return err
+			}
+		}
+	}
+	for _, cs := range self.Chars {
+		if len(cs.Quote) == 0 {
+			return fmt.Errorf("character literal without quote")
+		}
+	}
+	for _, region := range self.SkipRegions {
+		if len(region.Begin) == 0 {
+			return fmt.Errorf("skip region without begin")
+		}
+		if len(region.Nest) != 0 && len(region.End) == 0 {
+			return fmt.Errorf("nested skip region without end")
+		}
+		if region.Regex {
+			for _, pattern := range append([]string{region.Begin, region.Nest}, region.End...) {
+				if len(pattern) == 0 {
+					continue
+				}
+				if err := checkRegex(strings.TrimPrefix(pattern, "^")); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, embedded := range self.Embedded {
+		if len(embedded.End) == 0 || len(embedded.Language) == 0 {
+			return fmt.Errorf("embedded language without end or language")
+		}
+		if err := checkRegex(embedded.Begin); err != nil {
 			return err
 		}
 	}
+	for _, ss := range self.Strings {
+		if ss.Regex {
+			if err := checkRegex(ss.Begin); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-func (self *CommentStyle) Dump(name string, extensions []string) {
+func (self *CommentStyle) Dump(name string, extensions, filenames, shebangs []string) {
 	fmt.Printf("\x1b[1m%s\x1b[m\n", name)
+	if self.Notebook {
+		fmt.Println("      Notebook: cells are checked with the styles of their languages")
+	}
+	if self.Prose {
+		fmt.Println("         Prose: everything except comments, strings, and skip regions is checked")
+	}
 	last := len(self.Line) - 1
 	if last >= 0 {
 		fmt.Print("   Line styles: ")
@@ -88,10 +215,87 @@ func (self *CommentStyle) Dump(name string, extensions []string) {
 		}
 		fmt.Println()
 	}
+	if len(self.DocLine) != 0 || len(self.DocBlockBegin) != 0 {
+		fmt.Print("  Doc comments: ")
+		docs := util.Copy(self.DocLine)
+		for i, begin := range self.DocBlockBegin {
+			docs = append(docs, begin+"\x1b[2m...\x1b[22m"+self.DocBlockEnd[i])
+		}
+		fmt.Println(strings.Join(docs, ", "))
+	}
+	if self.DocStrings {
+		fmt.Println("   Doc strings: block comments starting modules, functions, and classes")
+	}
+	if len(self.DocBefore) != 0 {
+		fmt.Print("    Doc before: ")
+		fmt.Println(strings.Join(self.DocBefore, ", "))
+	}
+	last = len(self.Chars) - 1
+	if last >= 0 {
+		fmt.Print("    Characters: ")
+		for i, cs := range self.Chars {
+			fmt.Printf(
+				"%s%s\x1b[;2mc\x1b[22m%s%s\x1b[m",
+				FallbackCommentColor,
+				cs.Quote,
+				FallbackCommentColor,
+				cs.Quote,
+			)
+			if i != last {
+				fmt.Print(", ")
+			}
+		}
+		fmt.Println()
+	}
+	last = len(self.SkipRegions) - 1
+	if last >= 0 {
+		fmt.Print("  Skip regions: ")
+		for i, region := range self.SkipRegions {
+			fmt.Printf("%s\x1b[2m...\x1b[22m%s", region.Begin, strings.Join(region.End, "|"))
+			if i != last {
+				fmt.Print(", ")
+			}
+		}
+		fmt.Println()
+	}
+	if len(self.FrontMatter) != 0 {
+		fmt.Print("  Front matter: ")
+		fmt.Println(strings.Join(self.FrontMatter, ", "))
+	}
+	if len(self.CutOff) != 0 {
+		fmt.Print("       Cut off: ")
+		fmt.Println(strings.Join(self.CutOff, ", "))
+	}
+	last = len(self.Embedded) - 1
+	if last >= 0 {
+		fmt.Print("      Embedded: ")
+		for i, embedded := range self.Embedded {
+			fmt.Printf(
+				"%s\x1b[2m...\x1b[22m%s (%s)",
+				embedded.Begin,
+				embedded.End,
+				embedded.Language,
+			)
+			if i != last {
+				fmt.Print(", ")
+			}
+		}
+		fmt.Println()
+	}
 	if len(extensions) == 1 {
 		fmt.Print("     Extension: ")
-	} else {
+	} else if len(extensions) > 1 {
 		fmt.Print("    Extensions: ")
 	}
-	fmt.Println(strings.Join(extensions, ", "))
+	if len(extensions) != 0 {
+		fmt.Println(strings.Join(extensions, ", "))
+	}
+	if len(filenames) != 0 {
+		fmt.Print("    File names: ")
+		fmt.Println(strings.Join(filenames, ", "))
+	}
+	if len(shebangs) != 0 {
+		fmt.Print("      Shebangs: ")
+		fmt.Println(strings.Join(shebangs, ", "))
+	}
 }
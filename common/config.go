@@ -18,20 +18,47 @@ const (
 var FallbackCommentColor string
 
 type CfgGeneral struct {
-	Backup              bool     `toml:"backup"`
-	BottomStatus        bool     `toml:"bottom-status"`
-	BoxStyle            string   `toml:"box-style"`
-	DimCode             bool     `toml:"dim-code"`
-	FilterCommentedCode bool     `toml:"filter-commented-code"`
-	Filters             []string `toml:"filters"`
-	HighlightCommands   []string `toml:"highlight-commands"`
-	IgnoreCase          bool     `toml:"ignore-case"`
-	IgnoreLists         []string `toml:"ignore-lists"`
-	ItalicToUnderline   bool     `toml:"italic-to-underline"`
-	Layout              string   `toml:"layout"`
-	Mouse               bool     `toml:"mouse"`
-	Suggestions         int      `toml:"suggestions"`
-	TabSize             int      `toml:"tab-size"`
+	ApplySavedReplacements bool     `toml:"apply-saved-replacements"`
+	Backup                 bool     `toml:"backup"`
+	BottomStatus           bool     `toml:"bottom-status"`
+	BoxStyle               string   `toml:"box-style"`
+	DimCode                bool     `toml:"dim-code"`
+	DocOnly                bool     `toml:"doc-only"`
+	FilterCommentedCode    bool     `toml:"filter-commented-code"`
+	Filters                []string `toml:"filters"`
+	HighlightCommands      []string `toml:"highlight-commands"`
+	IgnoreCase             bool     `toml:"ignore-case"`
+	IgnoreLists            []string `toml:"ignore-lists"`
+	ItalicToUnderline      bool     `toml:"italic-to-underline"`
+	Layout                 string   `toml:"layout"`
+	LearnIdentifiers       bool     `toml:"learn-identifiers"`
+	Mouse                  bool     `toml:"mouse"`
+	ProseInDirectories     bool     `toml:"prose-in-directories"`
+	ReplacementFiles       []string `toml:"replacement-files"`
+	SaveChoices            bool     `toml:"save-choices"`
+	SavedReplacements      string   `toml:"saved-replacements"`
+	SkipRegions            bool     `toml:"skip-regions"`
+	Suggestions            int      `toml:"suggestions"`
+	TabSize                int      `toml:"tab-size"`
+}
+
+// CfgSkip defines which classes of text that is not prose are never checked.
+type CfgSkip struct {
+	Urls   bool `toml:"urls"`
+	Emails bool `toml:"emails"`
+	Paths  bool `toml:"paths"`
+	Hex    bool `toml:"hex"`
+	Uuids  bool `toml:"uuids"`
+	Base64 bool `toml:"base64"`
+}
+
+// CfgGrammar configures the checks for mistakes that are not misspellings.
+type CfgGrammar struct {
+	// The preferred variant of English, en_US or en_GB.
+	Locale        string `toml:"locale"`
+	RepeatedWords bool   `toml:"repeated-words"`
+	// Groups of words that are often confused with each other.
+	Confusables [][]string `toml:"confusables"`
 }
 
 type CfgColors struct {
@@ -45,31 +72,63 @@ type CfgColors struct {
 
 type Config struct {
 	Extensions    map[string][]string
+	Filenames     map[string][]string `toml:"filenames"`
+	Shebangs      map[string][]string `toml:"shebangs"`
 	Styles        map[string]CommentStyle
 	General       CfgGeneral
+	Skip          CfgSkip
+	Grammar       CfgGrammar
 	Colors        CfgColors
 	AspellOptions map[string]string `toml:"aspell-options"`
+	// Named dictionaries, given as aspell options.
+	Dictionaries    map[string]map[string]string `toml:"dictionaries"`
+	DictionaryRules []DictionaryRule             `toml:"dictionary-rules"`
+	// Misspelled words that are replaced without asking.
+	Replacements map[string]string `toml:"replacements"`
 }
 
 func DefaultConfig() Config {
 	return Config{
 		Extensions: make(map[string][]string),
+		Filenames:  make(map[string][]string),
+		Shebangs:   make(map[string][]string),
 		Styles:     make(map[string]CommentStyle),
 		General: CfgGeneral{
-			Backup:              true,
-			BottomStatus:        false,
-			BoxStyle:            "rounded",
-			DimCode:             true,
-			FilterCommentedCode: false,
-			Filters:             []string{},
-			HighlightCommands:   []string{},
-			IgnoreCase:          true,
-			IgnoreLists:         []string{".spellcheck_comments_ignorelist"},
-			ItalicToUnderline:   false,
-			Layout:              "default",
-			Mouse:               true,
-			Suggestions:         -1,
-			TabSize:             4,
+			ApplySavedReplacements: false,
+			Backup:                 true,
+			BottomStatus:           false,
+			BoxStyle:               "rounded",
+			DimCode:                true,
+			DocOnly:                false,
+			FilterCommentedCode:    false,
+			Filters:                []string{},
+			HighlightCommands:      []string{},
+			IgnoreCase:             true,
+			IgnoreLists:            []string{".spellcheck_comments_ignorelist"},
+			ItalicToUnderline:      false,
+			Layout:                 "default",
+			LearnIdentifiers:       false,
+			Mouse:                  true,
+			ProseInDirectories:     false,
+			ReplacementFiles:       []string{".spellcheck_comments_replacements"},
+			SaveChoices:            false,
+			SavedReplacements:      ".spellcheck_comments_saved_replacements",
+			SkipRegions:            true,
+			Suggestions:            -1,
+			TabSize:                4,
+		},
+		Skip: CfgSkip{
+			Urls:   true,
+			Emails: true,
+			Paths:  true,
+			Hex:    true,
+			Uuids:  true,
+			Base64: true,
+		},
+		Grammar: CfgGrammar{
+			Locale:        "",
+			RepeatedWords: false,
+			Confusables:   [][]string{},
 		},
 		Colors: CfgColors{
 			BoxOutline:        "\x1b[38;5;213m",
@@ -79,7 +138,10 @@ func DefaultConfig() Config {
 			Menu:              "\x1b[48;5;61;38;5;232m",
 			StatusBar:         "\x1b[38;5;251;7m",
 		},
-		AspellOptions: make(map[string]string),
+		AspellOptions:   make(map[string]string),
+		Dictionaries:    make(map[string]map[string]string),
+		DictionaryRules: []DictionaryRule{},
+		Replacements:    make(map[string]string),
 	}
 }
 
@@ -96,6 +158,14 @@ func LoadConfig(pathname string) Config {
 			Fatal("invalid comment style: %s: %s", name, err)
 		}
 	}
+	for _, rule := range cfg.DictionaryRules {
+		if err := rule.check(&cfg); err != nil {
+			Fatal("invalid dictionary rule: %s", err)
+		}
+	}
+	if _, err := NewSpellingVariants(cfg.Grammar.Locale); err != nil {
+		Fatal("%s", err)
+	}
 	if cfg.Colors.Comment == commentColorDefault {
 		if len(cfg.General.HighlightCommands) == 0 {
 			cfg.Colors.Comment = DefaultCommentColor
@@ -116,17 +186,6 @@ func LoadConfig(pathname string) Config {
 	return cfg
 }
 
-func (self *Config) GetStyle(extension string) CommentStyle {
-	for style, extensions := range self.Extensions {
-		for _, ext := range extensions {
-			if ext == extension {
-				return self.Styles[style]
-			}
-		}
-	}
-	panic("unreachable")
-}
-
 func (self *Config) Aspell() map[string]string {
 	return self.AspellOptions
 }
@@ -162,6 +221,11 @@ func (self *Config) DumpStyles() {
 			fmt.Println()
 		}
 		first = false
-		pair.style.Dump(pair.name, self.Extensions[pair.name])
+		pair.style.Dump(
+			pair.name,
+			self.Extensions[pair.name],
+			self.Filenames[pair.name],
+			self.Shebangs[pair.name],
+		)
 	}
 }
@@ -0,0 +1,229 @@
+package common
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	vimModeline = regexp.MustCompile(
+		`(?:^|\s)(?:vi|vim|ex):(?:.*?[\s:])?(?:ft|filetype|syntax)=([\w+-]+)`,
+	)
+	emacsModeline = regexp.MustCompile(`-\*-(.*?)-\*-`)
+	emacsMode     = regexp.MustCompile(`(?:^|;)\s*mode:\s*([\w+-]+)`)
+)
+
+// modelineLines is the number of lines at the beginning and end of a file that
+// are searched for vim modelines, this matches the default of vim.
+const modelineLines = 5
+
+// sortedStyleNames returns the names of all styles with user defined styles
+// before builtin ones so they take precedence if both claim the same file.
+func (self *Config) sortedStyleNames() []string {
+	names := make([]string, 0, len(self.Styles))
+	for name := range self.Styles {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a := strings.HasPrefix(names[i], "builtin")
+		b := strings.HasPrefix(names[j], "builtin")
+		if a != b {
+			return b
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// matchFilename checks the exact file names and, if globs is true, the glob
+// patterns of the [filenames] section.
+func (self *Config) matchFilename(names []string, pathname string, globs bool) Optional[string] {
+	base := filepath.Base(pathname)
+	for _, style := range names {
+		for _, pattern := range self.Filenames[style] {
+			if isGlob(pattern) != globs {
+				continue
+			}
+			if !globs {
+				if pattern == base {
+					return Some(style)
+				}
+				continue
+			}
+			target := base
+			if strings.ContainsRune(pattern, '/') {
+				target = filepath.ToSlash(filepath.Clean(pathname))
+			}
+			if ok, _ := filepath.Match(pattern, target); ok {
+				return Some(style)
+			}
+		}
+	}
+	return None[string]()
+}
+
+// shebangInterpreter returns the name of the interpreter in a shebang line.
+// Interpreters invoked through env are resolved.
+func shebangInterpreter(head string) string {
+	if !strings.HasPrefix(head, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(head[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, arg := range fields[1:] {
+			// Skip options (i.e. `-S`) and variable assignments.
+			if strings.HasPrefix(arg, "-") || strings.ContainsRune(arg, '=') {
+				continue
+			}
+			interpreter = filepath.Base(arg)
+			break
+		}
+	}
+	return interpreter
+}
+
+// stripVersion removes a trailing version number from an interpreter name,
+// i.e. python3.11 becomes python.
+func stripVersion(interpreter string) string {
+	return strings.TrimRight(interpreter, "0123456789.-")
+}
+
+func (self *Config) matchShebang(names []string, head string) Optional[string] {
+	interpreter := shebangInterpreter(head)
+	if len(interpreter) == 0 {
+		return None[string]()
+	}
+	stripped := stripVersion(interpreter)
+	for _, candidate := range []string{interpreter, stripped} {
+		for _, style := range names {
+			for _, name := range self.Shebangs[style] {
+				if name == candidate {
+					return Some(style)
+				}
+			}
+		}
+	}
+	return None[string]()
+}
+
+// modelineLanguage returns the language set by a vim or emacs modeline.
+func modelineLanguage(head, tail string) string {
+	headLines := strings.SplitN(head, "\n", modelineLines+1)
+	if len(headLines) > modelineLines {
+		headLines = headLines[:modelineLines]
+	}
+	// Emacs only looks at the first line, or the second one if the first one
+	// is a shebang.
+	for i, line := range headLines {
+		if i > 1 || (i == 1 && !strings.HasPrefix(headLines[0], "#!")) {
+			break
+		}
+		if m := emacsModeline.FindStringSubmatch(line); m != nil {
+			vars := strings.TrimSpace(m[1])
+			if mode := emacsMode.FindStringSubmatch(vars); mode != nil {
+				return mode[1]
+			} else if !strings.ContainsRune(vars, ':') {
+				return vars
+			}
+		}
+	}
+	tailLines := strings.Split(strings.TrimRight(tail, "\n"), "\n")
+	if len(tailLines) > modelineLines {
+		tailLines = tailLines[len(tailLines)-modelineLines:]
+	}
+	for _, line := range append(headLines, tailLines...) {
+		if m := vimModeline.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// matchLanguageName finds the style for a language name as used in modelines.
+// The name may be the name of the style, an interpreter from the [shebangs]
+// section, or an extension.
+func (self *Config) matchLanguageName(names []string, language string) Optional[string] {
+	language = strings.ToLower(language)
+	for _, style := range names {
+		if style == language || style == "builtin-"+language {
+			return Some(style)
+		}
+	}
+	for _, table := range []map[string][]string{self.Shebangs, self.Extensions} {
+		for _, style := range names {
+			for _, name := range table[style] {
+				if strings.ToLower(name) == language {
+					return Some(style)
+				}
+			}
+		}
+	}
+	return None[string]()
+}
+
+// LanguageStyle returns the name of the style for a language name, see
+// matchLanguageName.
+func (self *Config) LanguageStyle(language string) Optional[string] {
+	return self.matchLanguageName(self.sortedStyleNames(), language)
+}
+
+// FileExtension returns the extension of a file name without the dot. Files
+// without extension give an empty string.
+func FileExtension(pathname string) string {
+	base := filepath.Base(pathname)
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 {
+		return ""
+	}
+	return base[dot+1:]
+}
+
+func (self *Config) matchExtension(names []string, pathname string) Optional[string] {
+	ext := FileExtension(pathname)
+	if len(ext) == 0 {
+		return None[string]()
+	}
+	for _, style := range names {
+		for _, e := range self.Extensions[style] {
+			if e == ext {
+				return Some(style)
+			}
+		}
+	}
+	return None[string]()
+}
+
+// DetectStyle returns the name of the comment style to use for a file. The
+// rules are tried in this order: exact file names, file name globs, shebang,
+// modeline, and extension. head and tail are the first and last bytes of the
+// file and are used for the shebang and modeline checks.
+func (self *Config) DetectStyle(pathname, head, tail string) Optional[string] {
+	names := self.sortedStyleNames()
+	if style := self.matchFilename(names, pathname, false); style.IsSome() {
+		return style
+	}
+	if style := self.matchFilename(names, pathname, true); style.IsSome() {
+		return style
+	}
+	if style := self.matchShebang(names, head); style.IsSome() {
+		return style
+	}
+	if language := modelineLanguage(head, tail); len(language) != 0 {
+		if style := self.matchLanguageName(names, language); style.IsSome() {
+			return style
+		}
+	}
+	return self.matchExtension(names, pathname)
+}
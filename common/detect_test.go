@@ -0,0 +1,60 @@
+package common
+
+import "testing"
+
+func detectConfig() Config {
+	cfg := DefaultConfig()
+	for _, name := range []string{"c", "python", "shell", "make"} {
+		cfg.Styles[name] = CommentStyle{}
+	}
+	cfg.Extensions["c"] = []string{"c", "h"}
+	cfg.Extensions["python"] = []string{"py"}
+	cfg.Extensions["shell"] = []string{"sh"}
+	cfg.Filenames["make"] = []string{"Makefile", "*.mk"}
+	cfg.Filenames["shell"] = []string{"scripts/*"}
+	cfg.Shebangs["python"] = []string{"python"}
+	cfg.Shebangs["shell"] = []string{"sh", "bash"}
+	return cfg
+}
+
+func TestDetectStyle(t *testing.T) {
+	cfg := detectConfig()
+	cases := []struct {
+		pathname string
+		head     string
+		style    string
+	}{
+		{"src/main.c", "", "c"},
+		{"src/Makefile", "", "make"},
+		{"rules.mk", "", "make"},
+		{"scripts/build", "", "shell"},
+		// Exact names take precedence over globs
+		{"scripts/Makefile", "", "make"},
+		{"tool", "#!/usr/bin/env python3\n", "python"},
+		{"tool", "#!/usr/bin/env -S VAR=1 python3.11 -u\n", "python"},
+		{"tool.c", "#!/bin/bash\n", "shell"},
+		{"tool", "# -*- mode: python; coding: utf-8 -*-\n", "python"},
+		{"tool", "#!/bin/foo\n# -*- sh -*-\n", "shell"},
+		{"tool", "code\n/* vim: set ft=c : */\n", "c"},
+		{"tool", "# vim:ft=py\n", "python"},
+		{"README", "", ""},
+		{"archive.tar", "", ""},
+	}
+	for _, c := range cases {
+		got := ""
+		cfg.DetectStyle(c.pathname, c.head, c.head).Then(func(s string) {
+			got = s
+		})
+		if got != c.style {
+			t.Errorf("%s: got %q, expected %q", c.pathname, got, c.style)
+		}
+	}
+}
+
+func TestModelineInTail(t *testing.T) {
+	cfg := detectConfig()
+	style := cfg.DetectStyle("tool", "no modeline here\n", "...\n# vim: syntax=sh\n")
+	if !style.IsSome() || style.Unwrap() != "shell" {
+		t.Errorf("modeline at end of file not detected")
+	}
+}
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/trustmaster/go-aspell"
+)
+
+// DefaultDictionary is the name of the dictionary created from the
+// [aspell-options] section.
+const DefaultDictionary = "default"
+
+// DictionaryRule selects the dictionaries for the files matching any of its
+// globs or using any of its styles.
+type DictionaryRule struct {
+	Globs        []string `toml:"globs"`
+	Styles       []string `toml:"styles"`
+	Dictionaries []string `toml:"dictionaries"`
+}
+
+// matchPath checks if a glob matches the path or, if it contains a slash, any
+// of its parent directories.
+func matchPath(glob, pathname string) bool {
+	if !strings.ContainsRune(glob, '/') {
+		ok, _ := filepath.Match(glob, filepath.Base(pathname))
+		return ok
+	}
+	glob = strings.TrimSuffix(glob, "/")
+	for target := filepath.ToSlash(filepath.Clean(pathname)); target != "."; {
+		if ok, _ := filepath.Match(glob, target); ok {
+			return true
+		}
+		dir := filepath.ToSlash(filepath.Dir(target))
+		if dir == target {
+			break
+		}
+		target = dir
+	}
+	return false
+}
+
+func (self *DictionaryRule) matches(pathname, style string) bool {
+	for _, name := range self.Styles {
+		if name == style || "builtin-"+name == style {
+			return true
+		}
+	}
+	for _, glob := range self.Globs {
+		if matchPath(glob, pathname) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *DictionaryRule) check(cfg *Config) error {
+	if len(self.Dictionaries) == 0 {
+		return fmt.Errorf("rule without dictionaries")
+	}
+	for _, name := range self.Dictionaries {
+		if _, ok := cfg.Dictionaries[name]; !ok && name != DefaultDictionary {
+			return fmt.Errorf("unknown dictionary: %s", name)
+		}
+	}
+	for _, glob := range self.Globs {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("%s: %s", glob, err)
+		}
+	}
+	return nil
+}
+
+// FileDictionaries returns the names of the dictionaries for a file, these are
+// the dictionaries of the first matching rule.
+func (self *Config) FileDictionaries(pathname, style string) []string {
+	for _, rule := range self.DictionaryRules {
+		if rule.matches(pathname, style) {
+			return rule.Dictionaries
+		}
+	}
+	return []string{DefaultDictionary}
+}
+
+// Dictionaries holds a speller for every configured dictionary and the ones
+// that are active. A word is correct if any active dictionary accepts it.
+type Dictionaries struct {
+	spellers map[string]aspell.Speller
+	active   []aspell.Speller
+	// Identifiers that are correct in all dictionaries, may be nil.
+	vocabulary *Vocabulary
+}
+
+// NewDictionaries creates the spellers for all dictionaries, the default
+// dictionary is active. The options of named dictionaries are added to the
+// [aspell-options] section.
+func NewDictionaries(cfg *Config) (Dictionaries, error) {
+	spellers := make(map[string]aspell.Speller)
+	deleteAll := func() {
+		for _, speller := range spellers {
+			speller.Delete()
+		}
+	}
+	speller, err := aspell.NewSpeller(cfg.Aspell())
+	if err != nil {
+		return Dictionaries{}, err
+	}
+	spellers[DefaultDictionary] = speller
+	for name, options := range cfg.Dictionaries {
+		merged := make(map[string]string)
+		for key, value := range cfg.Aspell() {
+			merged[key] = value
+		}
+		for key, value := range options {
+			merged[key] = value
+		}
+		speller, err := aspell.NewSpeller(merged)
+		if err != nil {
+			deleteAll()
+			return Dictionaries{}, fmt.Errorf("%s: %s", name, err)
+		}
+		spellers[name] = speller
+	}
+	return Dictionaries{spellers, []aspell.Speller{spellers[DefaultDictionary]}, nil}, nil
+}
+
+func (self Dictionaries) Delete() {
+	for _, speller := range self.spellers {
+		speller.Delete()
+	}
+}
+
+// SetVocabulary sets the identifiers that are always correct.
+func (self *Dictionaries) SetVocabulary(vocabulary *Vocabulary) {
+	self.vocabulary = vocabulary
+}
+
+// Select returns the dictionaries with the given ones active, unknown names
+// are ignored. Returns false if none of the names are known.
+func (self Dictionaries) Select(names []string) (Dictionaries, bool) {
+	active := []aspell.Speller{}
+	for _, name := range names {
+		if speller, ok := self.spellers[name]; ok {
+			active = append(active, speller)
+		}
+	}
+	if len(active) == 0 {
+		return self, false
+	}
+	return Dictionaries{self.spellers, active, self.vocabulary}, true
+}
+
+// Check returns true if any active dictionary accepts the word or it's a known
+// identifier.
+func (self Dictionaries) Check(word string) bool {
+	if self.vocabulary != nil && self.vocabulary.Contains(word) {
+		return true
+	}
+	for _, speller := range self.active {
+		if speller.Check(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Suggest merges the suggestions of the active dictionaries, taking the best
+// remaining suggestion of each dictionary in turn. Similar identifiers are
+// suggested first.
+func (self Dictionaries) Suggest(word string) []string {
+	lists := make([][]string, len(self.active))
+	for i, speller := range self.active {
+		lists[i] = speller.Suggest(word)
+	}
+	suggestions := []string{}
+	seen := make(map[string]bool)
+	if self.vocabulary != nil {
+		for _, identifier := range self.vocabulary.Suggest(word) {
+			seen[identifier] = true
+			suggestions = append(suggestions, identifier)
+		}
+	}
+	for rank := 0; ; rank++ {
+		done := true
+		for _, list := range lists {
+			if rank < len(list) {
+				done = false
+				if !seen[list[rank]] {
+					seen[list[rank]] = true
+					suggestions = append(suggestions, list[rank])
+				}
+			}
+		}
+		if done {
+			return suggestions
+		}
+	}
+}
+
+// Replace tells the active dictionaries about a chosen replacement so they
+// can improve their suggestions.
+func (self Dictionaries) Replace(misspelled, correct string) {
+	for _, speller := range self.active {
+		speller.Replace(misspelled, correct)
+	}
+}
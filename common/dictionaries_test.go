@@ -0,0 +1,33 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileDictionaries(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Dictionaries["de"] = map[string]string{"lang": "de"}
+	cfg.DictionaryRules = []DictionaryRule{
+		{Globs: []string{"docs/de"}, Dictionaries: []string{"de"}},
+		{Globs: []string{"*.de.md"}, Dictionaries: []string{"de", "default"}},
+		{Styles: []string{"rst"}, Dictionaries: []string{"de"}},
+	}
+	cases := []struct {
+		pathname string
+		style    string
+		expected []string
+	}{
+		{"docs/de/index.md", "builtin-markdown", []string{"de"}},
+		{"./docs/de/api/index.md", "builtin-markdown", []string{"de"}},
+		{"docs/default.md", "builtin-markdown", []string{"default"}},
+		{"src/README.de.md", "builtin-markdown", []string{"de", "default"}},
+		{"index.rst", "builtin-rst", []string{"de"}},
+		{"main.go", "builtin-go", []string{"default"}},
+	}
+	for _, c := range cases {
+		if got := cfg.FileDictionaries(c.pathname, c.style); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: got %v, expected %v", c.pathname, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+// ExactPrefix marks a replacement that is used with the case it's written in,
+// otherwise it follows the case of the replaced words.
+const ExactPrefix = "="
+
+// Replacement is the replacement for all occurrences of a word.
+type Replacement struct {
+	// The replacement, in lower case if it follows the case of each
+	// occurrence (see util.BaseCase).
+	Text string
+	// Set if the replacement is used as it is.
+	Exact bool
+}
+
+// NewReplacement creates the replacement for word from the text entered for
+// it, which is exact if it starts with ExactPrefix.
+func NewReplacement(text, word string) Replacement {
+	if strings.HasPrefix(text, ExactPrefix) {
+		return Replacement{strings.TrimPrefix(text, ExactPrefix), true}
+	}
+	return Replacement{util.BaseCase(text, word), false}
+}
+
+// Apply returns the replacement for an occurrence.
+func (self Replacement) Apply(word string) string {
+	if self.Exact {
+		return self.Text
+	}
+	return util.FollowCase(self.Text, word)
+}
+
+// String returns the replacement as it's written in replacement files.
+func (self Replacement) String() string {
+	if self.Exact {
+		return ExactPrefix + self.Text
+	}
+	return self.Text
+}
+
+// Replacements maps misspelled words to the words that automatically replace
+// them.
+type Replacements struct {
+	words map[string]Replacement
+	caser *cases.Caser
+}
+
+func NewReplacements(ignoreCase bool) Replacements {
+	var caser *cases.Caser
+	if ignoreCase {
+		caser = new(cases.Caser)
+		*caser = cases.Fold()
+	}
+	return Replacements{
+		words: make(map[string]Replacement),
+		caser: caser,
+	}
+}
+
+// transform applies case folding if enabled.
+func (self *Replacements) transform(word string) string {
+	if self.caser != nil {
+		word = self.caser.String(word)
+	}
+	return word
+}
+
+// Add adds a replacement, replacing any previous one for the word. If the
+// replacement only follows the case of the word it's applied with the case of
+// each occurrence, replacements starting with ExactPrefix are used as they are.
+func (self *Replacements) Add(word, replacement string) {
+	self.words[self.transform(word)] = NewReplacement(replacement, word)
+}
+
+// Merge adds the replacements of other for words that have none yet.
+func (self *Replacements) Merge(other *Replacements) {
+	for word, replacement := range other.words {
+		word = self.transform(word)
+		if _, ok := self.words[word]; !ok {
+			self.words[word] = replacement
+		}
+	}
+}
+
+// Lookup returns the replacement for a word.
+func (self *Replacements) Lookup(word string) Optional[Replacement] {
+	if replacement, ok := self.words[self.transform(word)]; ok {
+		return Some(replacement)
+	}
+	return None[Replacement]()
+}
+
+// Get returns the replacement for a word, with the case of the word.
+func (self *Replacements) Get(word string) Optional[string] {
+	if replacement, ok := self.words[self.transform(word)]; ok {
+		return Some(replacement.Apply(word))
+	}
+	return None[string]()
+}
@@ -0,0 +1,45 @@
+package common
+
+import "testing"
+
+func TestReplacements(t *testing.T) {
+	replacements := NewReplacements(true)
+	replacements.Add("teh", "the")
+	replacements.Add("Recieve", "receive")
+	replacements.Add("recieve", "Receive")
+	saved := NewReplacements(true)
+	saved.Add("teh", "ten")
+	saved.Add("occured", "occurred")
+	replacements.Merge(&saved)
+	cases := []struct {
+		word        string
+		replacement Optional[string]
+	}{
+		{"teh", Some("the")},
+		{"TEH", Some("THE")},
+		{"Teh", Some("The")},
+		{"Recieve", Some("Receive")},
+		{"recieve", Some("Receive")},
+		{"occured", Some("occurred")},
+		{"the", None[string]()},
+	}
+	for _, c := range cases {
+		if got := replacements.Get(c.word); got != c.replacement {
+			t.Errorf("%s: got %v, expected %v", c.word, got, c.replacement)
+		}
+	}
+}
+
+func TestExactReplacement(t *testing.T) {
+	replacements := NewReplacements(true)
+	replacements.Add("teh", ExactPrefix+"the")
+	replacements.Add("ios", "iOS")
+	for word, expected := range map[string]string{"Teh": "the", "TEH": "the", "IOS": "iOS"} {
+		if got := replacements.Get(word); got != Some(expected) {
+			t.Errorf("%s: got %v, expected %s", word, got, expected)
+		}
+	}
+	if got := replacements.Lookup("teh").Unwrap().String(); got != "=the" {
+		t.Errorf("got %q, expected \"=the\"", got)
+	}
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+//go:embed en_variants.txt
+var enVariants string
+
+// SpellingVariants finds words spelled in the other variant of English than
+// the preferred one.
+type SpellingVariants struct {
+	// Maps lower case words of the other variant to the preferred spelling.
+	preferred map[string]string
+	// The name of the other variant.
+	other string
+}
+
+// variantForms returns the word and its inflected forms for the given flags.
+func variantForms(word, flags string) []string {
+	forms := []string{word}
+	if strings.ContainsAny(flags, "sv") {
+		forms = append(forms, word+"s")
+	}
+	if strings.ContainsRune(flags, 'v') {
+		if strings.HasSuffix(word, "e") {
+			forms = append(forms, word+"d", strings.TrimSuffix(word, "e")+"ing")
+		} else {
+			forms = append(forms, word+"ed", word+"ing")
+		}
+	}
+	return forms
+}
+
+// NewSpellingVariants creates the variants for a locale, en_US or en_GB. An
+// empty locale disables the check.
+func NewSpellingVariants(locale string) (SpellingVariants, error) {
+	variants := SpellingVariants{preferred: map[string]string{}}
+	var preferBritish bool
+	switch strings.ToLower(strings.ReplaceAll(locale, "-", "_")) {
+	case "":
+		return variants, nil
+	case "en_us":
+		variants.other = "British"
+	case "en_gb":
+		variants.other = "American"
+		preferBritish = true
+	default:
+		return variants, fmt.Errorf("unsupported locale: %s", locale)
+	}
+	for _, line := range strings.Split(enVariants, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		flags := ""
+		if len(fields) > 2 {
+			flags = fields[2]
+		}
+		preferred := variantForms(fields[0], flags)
+		other := variantForms(fields[1], flags)
+		if preferBritish {
+			preferred, other = other, preferred
+		}
+		for i, word := range other {
+			variants.preferred[word] = preferred[i]
+		}
+	}
+	return variants, nil
+}
+
+// Preferred returns the preferred spelling of a word if it is spelled in the
+// other variant, with the case of the word.
+func (self *SpellingVariants) Preferred(word string) Optional[string] {
+	if preferred, ok := self.preferred[strings.ToLower(word)]; ok {
+		return Some(util.MatchCase(preferred, word))
+	}
+	return None[string]()
+}
+
+// Reason describes why a word with a preferred spelling is a finding.
+func (self *SpellingVariants) Reason() string {
+	return fmt.Sprintf("%s spelling", self.other)
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+// vocabularySuggestions is the maximum number of identifiers suggested for a
+// word.
+const vocabularySuggestions = 3
+
+// Vocabulary is the set of identifiers used in the checked files. Comment
+// words matching an identifier, ignoring case, are correct.
+type Vocabulary struct {
+	// Maps the lower case identifiers to their spellings.
+	words map[string][]string
+}
+
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{make(map[string][]string)}
+}
+
+// Add adds an identifier.
+func (self *Vocabulary) Add(identifier string) {
+	key := strings.ToLower(identifier)
+	if !util.Contains(self.words[key], identifier) {
+		self.words[key] = append(self.words[key], identifier)
+	}
+}
+
+// Contains returns true if the word is an identifier with any case.
+func (self *Vocabulary) Contains(word string) bool {
+	_, ok := self.words[strings.ToLower(word)]
+	return ok
+}
+
+// Suggest returns the identifiers closest to the word, at most a third of its
+// characters may differ.
+func (self *Vocabulary) Suggest(word string) []string {
+	type candidate struct {
+		identifier string
+		distance   int
+	}
+	key := strings.ToLower(word)
+	length := utf8.RuneCountInString(key)
+	limit := util.Max(length/3, 1)
+	candidates := []candidate{}
+	for lower, spellings := range self.words {
+		if util.Abs(utf8.RuneCountInString(lower)-length) > limit {
+			continue
+		}
+		if distance := util.EditDistance(key, lower); distance <= limit {
+			for _, identifier := range spellings {
+				candidates = append(candidates, candidate{identifier, distance})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		return a.identifier < b.identifier
+	})
+	suggestions := []string{}
+	for i := 0; i < len(candidates) && i < vocabularySuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].identifier)
+	}
+	return suggestions
+}
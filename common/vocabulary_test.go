@@ -0,0 +1,35 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVocabulary(t *testing.T) {
+	vocabulary := NewVocabulary()
+	for _, identifier := range []string{"TextBuffer", "textBuffer", "SliceIndex", "tcell", "Text"} {
+		vocabulary.Add(identifier)
+	}
+	for _, word := range []string{"TextBuffer", "textbuffer", "TCELL"} {
+		if !vocabulary.Contains(word) {
+			t.Errorf("%s: not contained", word)
+		}
+	}
+	if vocabulary.Contains("TextBuffers") {
+		t.Errorf("TextBuffers: contained")
+	}
+	cases := []struct {
+		word     string
+		expected []string
+	}{
+		{"TextBufer", []string{"TextBuffer", "textBuffer"}},
+		{"SlideIndex", []string{"SliceIndex"}},
+		{"tcel", []string{"tcell"}},
+		{"Taxi", []string{}},
+	}
+	for _, c := range cases {
+		if got := vocabulary.Suggest(c.word); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: got %v, expected %v", c.word, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"strings"
+	"sync"
+)
+
+// WordCounts counts how often words appear in the comments of the project. The
+// files are parsed in the background so it may be used concurrently.
+type WordCounts struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+func NewWordCounts() *WordCounts {
+	return &WordCounts{counts: make(map[string]int)}
+}
+
+// Add counts an occurrence of a word, case is ignored.
+func (self *WordCounts) Add(word string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.counts[strings.ToLower(word)]++
+}
+
+// Count returns how often a word appeared so far.
+func (self *WordCounts) Count(word string) int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.counts[strings.ToLower(word)]
+}
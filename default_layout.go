@@ -12,6 +12,14 @@ import (
 	"github.com/JaMo42/spellcheck_comments/util"
 )
 
+// statusText returns the left side of the status bar.
+func statusText(fileName, message string) string {
+	if len(message) == 0 {
+		return fileName
+	}
+	return fmt.Sprintf("%s: %s", fileName, message)
+}
+
 type DefaultLayout struct {
 	highlight       tui.SliceIndex
 	bottomStatus    bool
@@ -20,6 +28,7 @@ type DefaultLayout struct {
 	menuContainer   tui.MenuContainer
 	globalKeys      tui.Dock
 	statusBar       tui.StatusBar
+	fileName        string
 	suggestionCount int
 }
 
@@ -30,7 +39,12 @@ func (self *DefaultLayout) Configure(cfg *Config) {
 
 func (self *DefaultLayout) SetSource(sf *SourceFile) {
 	self.source.SetTextBuffer(sf.Text())
-	self.statusBar.SetLeft(filepath.Clean(sf.Name()))
+	self.fileName = filepath.Clean(sf.Name())
+	self.statusBar.SetLeft(self.fileName)
+}
+
+func (self *DefaultLayout) SetMessage(message string) {
+	self.statusBar.SetLeft(statusText(self.fileName, message))
 }
 
 func (self *DefaultLayout) Show(index tui.SliceIndex) {
@@ -2,20 +2,26 @@ package main
 
 import (
 	"os"
+	"strings"
 
+	. "github.com/JaMo42/spellcheck_comments/common"
 	sf "github.com/JaMo42/spellcheck_comments/source_file"
 	"github.com/JaMo42/spellcheck_comments/tui"
 )
 
 type FileContext struct {
-	sf      sf.SourceFile
-	changes map[tui.SliceIndex]bool
+	sf sf.SourceFile
+	// Maps the changed slices to their original text.
+	changes map[tui.SliceIndex]string
+	// Maps the lines whose comment text was edited to their original text.
+	editedLines map[int]string
 }
 
 func NewFileContext(sf sf.SourceFile) FileContext {
 	return FileContext{
-		sf:      sf,
-		changes: make(map[tui.SliceIndex]bool),
+		sf:          sf,
+		changes:     make(map[tui.SliceIndex]string),
+		editedLines: make(map[int]string),
 	}
 }
 
@@ -29,40 +35,164 @@ func (self *FileContext) Word(id int) *sf.Word {
 
 // Change changes the text of a slice and adds it to the changes.
 func (self *FileContext) Change(index tui.SliceIndex, text string) {
+	if _, changed := self.changes[index]; !changed {
+		self.changes[index] = self.sf.Text().GetSlice(index).Text()
+	}
 	self.sf.Text().SetSliceText(index, text)
-	self.changes[index] = true
 }
 
-// RemoveChange removes a slice from the changes and sets its content to the
-// given original.
-func (self *FileContext) RemoveChange(index tui.SliceIndex, original string) {
-	self.sf.Text().SetSliceText(index, original)
-	delete(self.changes, index)
+// RemoveWord removes a word together with the whitespace after it, or before
+// it if there is none after it. Returns the changed slices.
+func (self *FileContext) RemoveWord(index tui.SliceIndex) []tui.SliceIndex {
+	texts := []string{}
+	indices := []tui.SliceIndex{}
+	position := 0
+	self.sf.Text().ForEachInLine(index.Line(), func(s string, i tui.SliceIndex) {
+		if i == index {
+			position = len(indices)
+		}
+		texts = append(texts, s)
+		indices = append(indices, i)
+	})
+	isSpace := func(i int) bool {
+		return i >= 0 && i < len(texts) && len(texts[i]) != 0 &&
+			len(strings.TrimSpace(texts[i])) == 0
+	}
+	changed := []tui.SliceIndex{index}
+	if isSpace(position + 1) {
+		changed = append(changed, indices[position+1])
+	} else if isSpace(position - 1) {
+		changed = append(changed, indices[position-1])
+	}
+	for _, i := range changed {
+		self.Change(i, "")
+	}
+	return changed
+}
+
+// ApplyReplacements changes all unchanged words that have an automatic
+// replacement. Returns the changed words.
+func (self *FileContext) ApplyReplacements(replacements *Replacements) []sf.Word {
+	applied := []sf.Word{}
+	for _, word := range self.sf.Words() {
+		automatic := word.Kind == sf.WordKind.Misspelled || word.Kind == sf.WordKind.Locale
+		if !automatic || self.SliceIsChanged(word.Index) {
+			continue
+		}
+		replacements.Get(word.Original).Then(func(replacement string) {
+			self.Change(word.Index, replacement)
+			applied = append(applied, word)
+		})
+	}
+	return applied
+}
+
+// lineEdit holds what is needed to undo editing the comment text of a line.
+type lineEdit struct {
+	state sf.LineState
+	// The changes in the line before the edit.
+	changes map[tui.SliceIndex]string
+	// Whether the line was edited before.
+	wasEdited bool
+}
+
+// originalLine returns the text of a line without the changes.
+func (self *FileContext) originalLine(line int) string {
+	var builder strings.Builder
+	self.sf.Text().ForEachInLine(line, func(s string, index tui.SliceIndex) {
+		if original, changed := self.changes[index]; changed {
+			s = original
+		}
+		builder.WriteString(s)
+	})
+	return builder.String()
+}
+
+// EditSpan replaces the text and words of a comment span with the ones of the
+// edited text. The changes in the line become part of the edit. Returns the
+// undo information and the id of the first word after the start of the span.
+func (self *FileContext) EditSpan(span sf.CommentSpan, edited *sf.SourceFile) (lineEdit, int) {
+	line := span.Line
+	_, wasEdited := self.editedLines[line]
+	edit := lineEdit{self.sf.SaveLine(line), make(map[tui.SliceIndex]string), wasEdited}
+	if !wasEdited {
+		self.editedLines[line] = self.originalLine(line)
+	}
+	for index, original := range self.changes {
+		if index.Line() == line {
+			edit.changes[index] = original
+			delete(self.changes, index)
+		}
+	}
+	return edit, self.sf.ReplaceSpan(span, edited)
+}
+
+// UndoEdit restores a line to its state before an edit.
+func (self *FileContext) UndoEdit(edit lineEdit) {
+	line := edit.state.Line()
+	self.sf.RestoreLine(edit.state)
+	for index := range self.changes {
+		if index.Line() == line {
+			delete(self.changes, index)
+		}
+	}
+	for index, original := range edit.changes {
+		self.changes[index] = original
+	}
+	if !edit.wasEdited {
+		delete(self.editedLines, line)
+	}
+}
+
+// RemoveChange removes a slice from the changes and restores its original
+// content.
+func (self *FileContext) RemoveChange(index tui.SliceIndex) {
+	if original, changed := self.changes[index]; changed {
+		self.sf.Text().SetSliceText(index, original)
+		delete(self.changes, index)
+	}
 }
 
 // SliceIsChanged returns true if the slice with the given index is already changed.
 func (self *FileContext) SliceIsChanged(index tui.SliceIndex) bool {
-	return self.changes[index]
+	_, changed := self.changes[index]
+	return changed
 }
 
 // IsChanged returns true if any changes are made to the file.
 func (self *FileContext) IsChanged() bool {
-	return len(self.changes) != 0
+	return len(self.changes) != 0 || len(self.editedLines) != 0
 }
 
 func (self *FileContext) AddToBackup(b *Backup) {
 	b.SetFile(self.sf.Name())
-	tb := self.sf.Text()
-	originals := make(map[tui.SliceIndex]string)
-	for _, w := range self.sf.Words() {
-		originals[w.Index] = w.Original
+	if encoder := self.sf.Encoder(); encoder != nil {
+		// The text does not match the file contents so the changed lines of
+		// the file itself are backed up.
+		data, _ := self.sf.Bytes()
+		original := strings.Split(string(encoder.Original()), "\n")
+		for i, line := range strings.Split(string(data), "\n") {
+			if i < len(original) && line != original[i] {
+				b.AddText(i, original[i])
+			}
+		}
+		return
 	}
+	tb := self.sf.Text()
 	for change := range self.changes {
-		b.AddLine(change.Line(), tb, originals)
+		if _, edited := self.editedLines[change.Line()]; !edited {
+			b.AddLine(change.Line(), tb, self.changes)
+		}
+	}
+	for line, original := range self.editedLines {
+		b.AddText(line, original)
 	}
 }
 
 func (self *FileContext) Write() error {
-	data := self.sf.String()
-	return os.WriteFile(self.sf.Name(), []byte(data), 0o644)
+	data, err := self.sf.Bytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(self.sf.Name(), data, 0o644)
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/parser"
+)
+
+func TestEditSpan(t *testing.T) {
+	cfg := DefaultConfig()
+	ignoreList := NewIgnoreList(false)
+	counts := NewWordCounts()
+	style := CommentStyle{Line: []string{"//"}}
+	source := "f() // alot of wrds, ok\n// teh end\n"
+	file := NewFileContext(parser.Parse("", source, style, Dictionaries{}, &cfg, &ignoreList, counts, false))
+	originals := func() []string {
+		words := []string{}
+		for _, word := range file.Source().Words() {
+			words = append(words, word.Original+"="+word.Slice.Text())
+		}
+		return words
+	}
+	before := originals()
+	file.Change(file.Word(2).Index, "words")
+	span := file.Source().CommentSpan(file.Word(0).Index).Unwrap()
+	if text := file.Source().SpanText(span); text != "alot of words, ok" {
+		t.Fatalf("span text: %q", text)
+	}
+	edited := parser.Parse("", "a lot of words", CommentStyle{Prose: true}, Dictionaries{}, &cfg, &ignoreList, counts, false)
+	edit, first := file.EditSpan(span, &edited)
+	if first != 0 {
+		t.Errorf("first word: %d", first)
+	}
+	if text := file.Source().String(); text != "f() // a lot of words\n// teh end\n" {
+		t.Errorf("edited text: %q", text)
+	}
+	expected := []string{"lot=lot", "of=of", "words=words", "teh=teh", "end=end"}
+	if words := originals(); !reflect.DeepEqual(words, expected) {
+		t.Errorf("edited words: %v", words)
+	}
+	if original := file.editedLines[0]; original != "f() // alot of wrds, ok" {
+		t.Errorf("original line: %q", original)
+	}
+	if !file.IsChanged() || file.SliceIsChanged(file.Word(2).Index) || len(file.changes) != 0 {
+		t.Errorf("changes not replaced by the edit")
+	}
+	file.UndoEdit(edit)
+	file.RemoveChange(file.Word(2).Index)
+	if text := file.Source().String(); text != source {
+		t.Errorf("restored text: %q", text)
+	}
+	if words := originals(); !reflect.DeepEqual(words, before) {
+		t.Errorf("restored words: %v", words)
+	}
+	if file.IsChanged() {
+		t.Errorf("restored file is changed")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+)
+
+// fixFiles applies the automatic replacements to the files without the
+// interactive interface and writes them. Each replacement is printed in the
+// `file:line:column: word -> replacement` format. Returns the names of the
+// written files.
+func fixFiles(
+	sourceFiles chan sf.SourceFile, replacements *Replacements, doBackup bool,
+) []string {
+	files := []FileContext{}
+	for source := range sourceFiles {
+		file := NewFileContext(source)
+		applied := file.ApplyReplacements(replacements)
+		if len(applied) == 0 {
+			continue
+		}
+		for _, word := range applied {
+			fmt.Printf(
+				"%s:%d:%d: %s -> %s\n",
+				source.Name(),
+				word.Index.Line()+1,
+				wordColumn(file.Source().Text(), word.Index),
+				word.Original,
+				replacements.Get(word.Original).Unwrap(),
+			)
+		}
+		files = append(files, file)
+	}
+	return writeFiles(files, doBackup)
+}
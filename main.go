@@ -9,13 +9,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/kballard/go-shellquote"
-	"github.com/trustmaster/go-aspell"
 
 	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/notebook"
 	"github.com/JaMo42/spellcheck_comments/parser"
 	sf "github.com/JaMo42/spellcheck_comments/source_file"
 	"github.com/JaMo42/spellcheck_comments/tui"
@@ -58,7 +59,15 @@ type Options struct {
 	globs               []string
 	dumpStyles          bool
 	filterCommentedCode bool
+	docOnly             bool
+	learnIdentifiers    bool
 	saveIgnoreList      OptionalStringArg
+	commitMsg           string
+	check               bool
+	staged              bool
+	stdin               bool
+	lang                string
+	fix                 bool
 }
 
 func parseArgs() (Options, []string) {
@@ -98,10 +107,42 @@ func parseArgs() (Options, []string) {
 		&options.filterCommentedCode, "fcc", false,
 		"filter commented code, even if disabled in the config",
 	)
+	flag.BoolVar(
+		&options.docOnly, "doc-only", false,
+		"only check doc comments, even if disabled in the config",
+	)
+	flag.BoolVar(
+		&options.learnIdentifiers, "learn-identifiers", false,
+		"accept identifiers from the code of the checked files in comments, even if disabled in the config",
+	)
 	flag.Var(
 		&options.saveIgnoreList, "save-ignore",
 		"append words added to the ignore list to a local ignore list file. Optionally specify the name of that file.",
 	)
+	flag.StringVar(
+		&options.commitMsg, "commit-msg", "",
+		"check a git commit message file, for use in a commit-msg hook",
+	)
+	flag.BoolVar(
+		&options.check, "check", false,
+		"print misspelled words instead of starting the interface and exit with status 1 if there are any",
+	)
+	flag.BoolVar(
+		&options.staged, "staged", false,
+		"check the files in the git index, only words in changed lines are reported",
+	)
+	flag.BoolVar(
+		&options.stdin, "stdin", false,
+		"check standard input and write the corrected buffer to standard output, requires -lang",
+	)
+	flag.StringVar(
+		&options.lang, "lang", "",
+		"the comment style, language, or extension used for -stdin",
+	)
+	flag.BoolVar(
+		&options.fix, "fix", false,
+		"only apply the automatic replacements and write the files without starting the interface",
+	)
 	flag.Parse()
 	if showVersion {
 		fmt.Printf("%s %s\n", appName, appVersion)
@@ -129,11 +170,11 @@ func parseArgs() (Options, []string) {
 func discover(files []string, dir string, filter func(string, bool) bool) []string {
 	dirContent, _ := os.ReadDir(dir)
 	for _, file := range dirContent {
-		name := file.Name()
+		pathname := fmt.Sprintf("%s/%s", dir, file.Name())
 		if file.IsDir() {
-			files = discover(files, fmt.Sprintf("%s/%s", dir, name), filter)
-		} else if filter(name, false) {
-			files = append(files, fmt.Sprintf("%s/%s", dir, name))
+			files = discover(files, pathname, filter)
+		} else if filter(pathname, false) {
+			files = append(files, pathname)
 		}
 	}
 	return files
@@ -142,7 +183,7 @@ func discover(files []string, dir string, filter func(string, bool) bool) []stri
 // getFiles gets the list of files based on the arguments. If an argument
 // specifies a file it is added to the list if it matches the filter.
 // If it specified a directory it is recursively traversed, adding all files
-// matching the filter. The filter receives the name if the file and whether it
+// matching the filter. The filter receives the path of the file and whether it
 // was an argument or found during directory discovery.
 func getFiles(args []string, filter func(string, bool) bool) []string {
 	files := []string{}
@@ -165,40 +206,94 @@ func getFiles(args []string, filter func(string, bool) bool) []string {
 	return files
 }
 
-// fileFilter returns a filter for use in the getFiles function. The returned
-// filter checks if  a comment style is defined for the files extension and
-// whether it matches the glob filter option, if provided.
-func fileFilter(cfg *Config, options *Options) func(filename string, direct bool) bool {
-	extensionFilter := func(filename string, _ bool) bool {
-		ext := fileExtension(filename)
-		for _, extensions := range cfg.Extensions {
-			if util.Contains(extensions, ext) {
-				return true
-			}
-		}
-		return false
+// detectionSize is the number of bytes read from the beginning and end of a
+// file for style detection.
+const detectionSize = 4096
+
+// readHeadTail reads the first and last detectionSize bytes of a file. For
+// small files both contain the whole file.
+func readHeadTail(filename string) (string, string) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", ""
 	}
-	if len(options.globs) == 0 {
-		return extensionFilter
+	defer file.Close()
+	buf := make([]byte, detectionSize)
+	n, _ := io.ReadFull(file, buf)
+	head := string(buf[:n])
+	if n < detectionSize {
+		return head, head
 	}
-	return func(filename string, direct bool) bool {
-		if !extensionFilter(filename, direct) {
-			return false
-		}
-		for _, glob := range options.globs {
-			if match, _ := filepath.Match(glob, filename); match {
-				return true
+	if _, err := file.Seek(-detectionSize, io.SeekEnd); err != nil {
+		return head, head
+	}
+	n, _ = io.ReadFull(file, buf)
+	return head, string(buf[:n])
+}
+
+// contentHeadTail returns the first and last detectionSize bytes of content.
+func contentHeadTail(content string) (string, string) {
+	if len(content) <= detectionSize {
+		return content, content
+	}
+	return content[:detectionSize], content[len(content)-detectionSize:]
+}
+
+// detectStyle returns the name of the comment style for a file.
+func detectStyle(cfg *Config, filename string) Optional[string] {
+	head, tail := readHeadTail(filename)
+	return cfg.DetectStyle(filename, head, tail)
+}
+
+// fileFilter returns a filter for use in the getFiles function. The returned
+// filter checks if a comment style can be determined for the file and whether
+// it matches the glob filter option, if provided. Files with prose styles are
+// only found in directories if enabled. The detected styles are added to
+// styles.
+func fileFilter(
+	cfg *Config, options *Options, styles map[string]string,
+) func(filename string, direct bool) bool {
+	styleFilter := func(filename string, direct bool) bool {
+		if style := detectStyle(cfg, filename); style.IsSome() {
+			if !direct && !cfg.General.ProseInDirectories && cfg.Styles[style.Unwrap()].Prose {
+				return false
 			}
+			styles[filename] = style.Unwrap()
+			return true
 		}
 		if direct {
 			log.Printf(
-				"%s: skipping %s: no comment style defined for extension",
+				"%s: skipping %s: no comment style defined for file",
 				InvocationName,
 				filename,
 			)
 		}
 		return false
 	}
+	if len(options.globs) == 0 {
+		return styleFilter
+	}
+	return func(filename string, direct bool) bool {
+		return matchGlobs(options.globs, filename) && styleFilter(filename, direct)
+	}
+}
+
+// matchGlobs returns true if the base name or the whole path of the file
+// matches any of the globs, or if there are no globs.
+func matchGlobs(globs []string, filename string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	pathname := filepath.Clean(filename)
+	for _, glob := range globs {
+		if match, _ := filepath.Match(glob, filepath.Base(pathname)); match {
+			return true
+		}
+		if match, _ := filepath.Match(glob, pathname); match {
+			return true
+		}
+	}
+	return false
 }
 
 // noHighlight is the error case for the highlight function.
@@ -239,10 +334,6 @@ func highlight(filename string, cfg *Config) (string, bool) {
 	return string(stdoutData), false
 }
 
-func fileExtension(filename string) string {
-	return *util.Back(strings.Split(filename, "."))
-}
-
 type GlobalControl struct {
 	key    rune
 	label  string
@@ -272,6 +363,7 @@ func globalControls() []tui.KeyAction {
 		x('I', "Ignore all", ActionIgnore{true}),
 		x('r', "Replace", ActionReplace{false}),
 		x('R', "Replace all", ActionReplace{true}),
+		x('e', "Edit comment line", ActionEdit{}),
 		x('u', "Undo last change", ActionUndo{}),
 		x('s', "Skip rest of file", ActionSkip{}),
 		x('x', "Exit", ActionExit{}),
@@ -279,49 +371,220 @@ func globalControls() []tui.KeyAction {
 	}
 }
 
+// commitMsgStyle is the style used for commit messages.
+const commitMsgStyle = "builtin-git-commit"
+
+// commentChar returns the character git uses for comments in commit messages
+// in the repository containing dir. If git chooses it automatically `#` is
+// used as we cannot know which one it chose.
+func commentChar(dir string) string {
+	out, err := git(dir, "config", "core.commentChar")
+	char := strings.TrimSpace(string(out))
+	if err != nil || len(char) == 0 || char == "auto" {
+		return "#"
+	}
+	return char
+}
+
+// withCommentChar returns the commit message style with the `#` at the
+// beginning of its comment and cut off tokens replaced by char.
+func withCommentChar(style CommentStyle, char string) CommentStyle {
+	replace := func(token string) string {
+		lineStart := strings.HasPrefix(token, "^")
+		text := strings.TrimPrefix(token, "^")
+		if strings.HasPrefix(text, "#") {
+			text = char + text[1:]
+		}
+		if lineStart {
+			return "^" + text
+		}
+		return text
+	}
+	style.Line = util.Map(style.Line, replace)
+	style.CutOff = util.Map(style.CutOff, replace)
+	return style
+}
+
+// parseOptions changes where parseFiles gets the files and their styles from.
+type parseOptions struct {
+	// Used for all files instead of detecting the style.
+	forceStyle Optional[string]
+	// If set the files are read from the git index and only words in the
+	// changed lines are kept, except for notebooks.
+	staged map[string]stagedFile
+	// The styles detected while searching the files.
+	styles map[string]string
+}
+
+// fileStyle returns the name of the style for a file.
+func fileStyle(filename string, options *parseOptions, cfg *Config) Optional[string] {
+	if options.forceStyle.IsSome() {
+		return options.forceStyle
+	}
+	if style, ok := options.styles[filename]; ok {
+		return Some(style)
+	}
+	if staged, ok := options.staged[filename]; ok {
+		head, tail := contentHeadTail(staged.content)
+		return cfg.DetectStyle(filename, head, tail)
+	}
+	return detectStyle(cfg, filename)
+}
+
+// collectIdentifiers adds the identifiers in the code of the given files to the
+// vocabulary. Notebooks are skipped.
+func collectIdentifiers(
+	names []string,
+	options *parseOptions,
+	cfg *Config,
+	vocabulary *Vocabulary,
+) {
+	for _, filename := range names {
+		styleName := fileStyle(filename, options, cfg)
+		if !styleName.IsSome() || cfg.Styles[styleName.Unwrap()].Notebook {
+			continue
+		}
+		var content string
+		if staged, ok := options.staged[filename]; ok {
+			content = staged.content
+		} else if data, err := os.ReadFile(filename); err == nil {
+			content = string(data)
+		} else {
+			continue
+		}
+		parser.CollectIdentifiers(content, cfg.Styles[styleName.Unwrap()], cfg, vocabulary)
+	}
+}
+
+// parseFiles parses the given files and sends those with misspelled words to
+// out. If learn-identifiers is enabled the identifiers of all files are
+// collected first.
 func parseFiles(
 	names []string,
+	options parseOptions,
 	cfg *Config,
-	speller aspell.Speller,
+	dictionaries Dictionaries,
 	ignoreList *IgnoreList,
+	counts *WordCounts,
 	out chan sf.SourceFile,
 ) {
+	if cfg.General.LearnIdentifiers {
+		vocabulary := NewVocabulary()
+		collectIdentifiers(names, &options, cfg, vocabulary)
+		dictionaries.SetVocabulary(vocabulary)
+	}
 	for _, filename := range names {
-		highlighted, failed := highlight(filename, cfg)
+		staged, isStaged := options.staged[filename]
+		styleName := fileStyle(filename, &options, cfg)
+		if !styleName.IsSome() {
+			continue
+		}
+		// The rules are checked when loading the config so there is always
+		// a known dictionary.
+		speller, _ := dictionaries.Select(cfg.FileDictionaries(filename, styleName.Unwrap()))
+		if cfg.Styles[styleName.Unwrap()].Notebook {
+			var data []byte
+			var err error
+			if isStaged {
+				data = []byte(staged.content)
+			} else if data, err = os.ReadFile(filename); err != nil {
+				continue
+			}
+			if sf, ok := parseNotebook(filename, data, cfg, speller, ignoreList, counts); ok && !sf.Ok() {
+				out <- sf
+			}
+			continue
+		}
+		var highlighted string
+		var failed bool
+		if isStaged && !staged.matchesWorkTree {
+			// The highlighters can only read the working tree file.
+			highlighted, failed = staged.content, true
+		} else {
+			highlighted, failed = highlight(filename, cfg)
+		}
 		if len(highlighted) == 0 {
 			continue
 		}
-		style := cfg.GetStyle(fileExtension(filename))
-		sf := parser.Parse(
+		style := cfg.Styles[styleName.Unwrap()]
+		source := parser.Parse(
 			filename,
 			highlighted,
 			style,
 			speller,
 			cfg,
 			ignoreList,
+			counts,
 			failed,
 		)
-		if !sf.Ok() {
-			out <- sf
+		if isStaged {
+			source.KeepWords(func(word sf.Word) bool {
+				return staged.changedLines[word.Index.Line()]
+			})
+		}
+		if !source.Ok() {
+			out <- source
 		}
 	}
 	close(out)
 }
 
+// styleOrEmpty returns the style for a language, or an empty style with only
+// the given prose setting if there is none.
+func styleOrEmpty(cfg *Config, language string, prose bool) CommentStyle {
+	if name := cfg.LanguageStyle(language); name.IsSome() {
+		return cfg.Styles[name.Unwrap()]
+	}
+	return CommentStyle{Prose: prose}
+}
+
+// parseNotebook parses a Jupyter notebook with the given contents. Returns
+// false if the file is not a valid notebook.
+func parseNotebook(
+	filename string,
+	data []byte,
+	cfg *Config,
+	speller Dictionaries,
+	ignoreList *IgnoreList,
+	counts *WordCounts,
+) (sf.SourceFile, bool) {
+	nb, err := notebook.Parse(data)
+	if err != nil {
+		log.Printf("%s: skipping %s: %s", InvocationName, filename, err)
+		return sf.SourceFile{}, false
+	}
+	return parser.ParseNotebook(
+		filename,
+		nb,
+		styleOrEmpty(cfg, "markdown", true),
+		styleOrEmpty(cfg, nb.Language, false),
+		speller,
+		cfg,
+		ignoreList,
+		counts,
+	), true
+}
+
 type Paths struct {
 	ConfigFile string
 	ConfigDir  Optional[string]
 }
 
+// homeDir returns the home directory of the user.
+func homeDir() (string, bool) {
+	home := os.Getenv("HOME")
+	if len(home) == 0 {
+		home = os.Getenv("Home")
+	}
+	return home, len(home) != 0
+}
+
 // configPath returns the path and directory of the config file.
 func configPath() (Paths, bool) {
 	configHome := os.Getenv("XDG_CONFIG_HOME")
 	if len(configHome) == 0 {
-		home := os.Getenv("HOME")
-		if len(home) == 0 {
-			home = os.Getenv("Home")
-		}
-		if len(home) == 0 {
+		home, ok := homeDir()
+		if !ok {
 			return Paths{}, false
 		}
 		configHome = fmt.Sprintf("%s/.config", home)
@@ -345,8 +608,9 @@ func configPath() (Paths, bool) {
 	return Paths{}, false
 }
 
-// collectIgnoreLists creates the ignore list from all ignore list files to use.
-func collectIgnoreLists(configPath Optional[string], cfg *Config) IgnoreList {
+// forEachListLine calls f for each line of the given files in the current
+// directory and the config directory, in that order.
+func forEachListLine(configPath Optional[string], filenames []string, f func(string)) {
 	dirs := []string{}
 	if cwd, err := os.Getwd(); err == nil {
 		dirs = append(dirs, cwd)
@@ -354,10 +618,7 @@ func collectIgnoreLists(configPath Optional[string], cfg *Config) IgnoreList {
 	configPath.Then(func(path string) {
 		dirs = append(dirs, path)
 	})
-	list := NewIgnoreList(cfg.General.IgnoreCase)
-	list.Add("TODO")
-	list.Add("FIXME")
-	for _, filename := range cfg.General.IgnoreLists {
+	for _, filename := range filenames {
 		for _, dir := range dirs {
 			pathname := fmt.Sprintf("%s/%s", dir, filename)
 			file, err := os.Open(pathname)
@@ -367,16 +628,136 @@ func collectIgnoreLists(configPath Optional[string], cfg *Config) IgnoreList {
 			scanner := bufio.NewScanner(file)
 			scanner.Split(bufio.ScanLines)
 			for scanner.Scan() {
-				word := scanner.Text()
-				if len(word) > 1 {
-					list.Add(word)
-				}
+				f(scanner.Text())
 			}
+			file.Close()
 		}
 	}
+}
+
+// collectIgnoreLists creates the ignore list from all ignore list files to use.
+func collectIgnoreLists(configPath Optional[string], cfg *Config) IgnoreList {
+	list := NewIgnoreList(cfg.General.IgnoreCase)
+	list.Add("TODO")
+	list.Add("FIXME")
+	forEachListLine(configPath, cfg.General.IgnoreLists, func(word string) {
+		if len(word) > 1 {
+			list.Add(word)
+		}
+	})
 	return list
 }
 
+// parseReplacement parses a line of a replacement file, it contains a word and
+// its replacement separated by whitespace. The replacement may contain spaces.
+func parseReplacement(line string) (string, string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", "", false
+	}
+	return fields[0], strings.Join(fields[1:], " "), true
+}
+
+// collectReplacements creates the automatic replacements from the config and
+// all replacement files. Replacements in the files take precedence over the
+// config, the ones in the current directory over the ones in the config
+// directory. If enabled, saved replacements are used for words that have no
+// other replacement.
+func collectReplacements(
+	configPath Optional[string], cfg *Config, saved *Replacements,
+) Replacements {
+	replacements := NewReplacements(cfg.General.IgnoreCase)
+	for word, replacement := range cfg.Replacements {
+		replacements.Add(word, replacement)
+	}
+	lines := [][2]string{}
+	forEachListLine(configPath, cfg.General.ReplacementFiles, func(line string) {
+		if word, replacement, ok := parseReplacement(line); ok {
+			lines = append(lines, [2]string{word, replacement})
+		}
+	})
+	for i := len(lines) - 1; i >= 0; i-- {
+		replacements.Add(lines[i][0], lines[i][1])
+	}
+	if cfg.General.ApplySavedReplacements {
+		replacements.Merge(saved)
+	}
+	return replacements
+}
+
+// loadSavedReplacements loads the saved "Replace all" decisions, later lines
+// take precedence.
+func loadSavedReplacements(cfg *Config) Replacements {
+	saved := NewReplacements(cfg.General.IgnoreCase)
+	if len(cfg.General.SavedReplacements) == 0 {
+		return saved
+	}
+	data, err := os.ReadFile(cfg.General.SavedReplacements)
+	if err != nil {
+		return saved
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if word, replacement, ok := parseReplacement(line); ok {
+			saved.Add(word, replacement)
+		}
+	}
+	return saved
+}
+
+// choicesPath returns the path of the file how often words were chosen as
+// replacements is saved in. It belongs to the user and not to a project so it
+// is in the data directory.
+func choicesPath() (string, bool) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if len(dataHome) == 0 {
+		home, ok := homeDir()
+		if !ok {
+			return "", false
+		}
+		dataHome = fmt.Sprintf("%s/.local/share", home)
+	}
+	return fmt.Sprintf("%s/spellcheck_comments/choices", dataHome), true
+}
+
+// loadSavedChoices loads how often words were chosen as replacements in
+// earlier runs into the suggestion ranker, if saving them is enabled.
+func loadSavedChoices(checker *SpellChecker, cfg *Config) {
+	if !cfg.General.SaveChoices {
+		return
+	}
+	if path, ok := choicesPath(); ok {
+		checker.ranker.load(path)
+	}
+}
+
+// saveChoices saves how often words were chosen as replacements if enabled.
+func saveChoices(checker *SpellChecker, cfg *Config) {
+	if !cfg.General.SaveChoices {
+		return
+	}
+	path, ok := choicesPath()
+	if !ok {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		err = checker.SaveChoices(path)
+	}
+	if err != nil {
+		log.Printf("%s: could not save chosen replacements: %s\n", InvocationName, err)
+	}
+}
+
+// saveReplacements saves the "Replace all" decisions if enabled.
+func saveReplacements(checker *SpellChecker, cfg *Config) {
+	if len(cfg.General.SavedReplacements) == 0 {
+		return
+	}
+	if err := checker.SaveReplacements(cfg.General.SavedReplacements); err != nil {
+		log.Printf("%s: could not save replacements: %s\n", InvocationName, err)
+	}
+}
+
 // appendFileLines appends a list of lines to the end of a file.
 func appendFileLines(filename string, lines []string) error {
 	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0600)
@@ -409,15 +790,35 @@ func appendFileLines(filename string, lines []string) error {
 	return nil
 }
 
+// saveIgnoreList appends the words ignored with "Ignore all" to an ignore list
+// file and prints the result to w.
+func saveIgnoreList(filename string, checker *SpellChecker, w io.Writer) {
+	additions := make([]string, len(checker.ignore))
+	additions = additions[:0]
+	for word := range checker.ignore {
+		additions = append(additions, word)
+	}
+	if err := appendFileLines(filename, additions); err != nil {
+		fmt.Fprintf(w, "Writing ignore list failed: %s", err)
+	} else {
+		fmt.Fprintf(w, "Saved ignore list to %s", filename)
+	}
+}
+
 func main() {
+	os.Exit(run())
+}
+
+// run runs the program and returns the exit status.
+func run() int {
 	log.SetFlags(0)
 	options, args := parseArgs()
 	if options.applyBackup {
 		RunBackup()
-		return
+		return 0
 	} else if options.applyBackupAll {
 		BackupRestoreAll()
-		return
+		return 0
 	}
 	paths, haveConfig := configPath()
 	var cfg Config
@@ -429,35 +830,92 @@ func main() {
 	MergeBuiltinStyles(&cfg)
 	if options.dumpStyles {
 		cfg.DumpStyles()
-		return
+		return 0
 	}
 	cfg.General.FilterCommentedCode =
 		cfg.General.FilterCommentedCode || options.filterCommentedCode
 	cfg.General.Backup = cfg.General.Backup || options.backup
+	cfg.General.DocOnly = cfg.General.DocOnly || options.docOnly
+	cfg.General.LearnIdentifiers = cfg.General.LearnIdentifiers || options.learnIdentifiers
 
 	ignoreList := collectIgnoreLists(paths.ConfigDir, &cfg)
+	saved := loadSavedReplacements(&cfg)
+	replacements := collectReplacements(paths.ConfigDir, &cfg, &saved)
+	if options.stdin {
+		return runStdin(&options, &cfg, &ignoreList, &replacements, &saved)
+	}
 
-	files := getFiles(args, fileFilter(&cfg, &options))
+	var files []string
+	parseOpts := parseOptions{forceStyle: None[string]()}
+	if len(options.commitMsg) != 0 {
+		style, ok := cfg.Styles[commitMsgStyle]
+		if !ok {
+			Fatal("no %s style defined", commitMsgStyle)
+		}
+		char := commentChar(filepath.Dir(options.commitMsg))
+		cfg.Styles[commitMsgStyle] = withCommentChar(style, char)
+		files = []string{options.commitMsg}
+		parseOpts.forceStyle = Some(commitMsgStyle)
+	} else if options.staged {
+		staged, err := getStagedFiles(options.globs)
+		if err != nil {
+			Fatal("%s", err)
+		}
+		for filename := range staged {
+			files = append(files, filename)
+		}
+		sort.Strings(files)
+		parseOpts.staged = staged
+	} else {
+		parseOpts.styles = make(map[string]string)
+		files = getFiles(args, fileFilter(&cfg, &options, parseOpts.styles))
+	}
 	if len(files) == 0 {
 		fmt.Println("No files")
-		return
+		return 0
 	}
 
-	speller, err := aspell.NewSpeller(cfg.Aspell())
+	dictionaries, err := NewDictionaries(&cfg)
 	if err != nil {
 		Fatal("could not create speller: %s", err.Error())
 	}
-	defer speller.Delete()
+	defer dictionaries.Delete()
+
+	sourceFiles := make(chan sf.SourceFile)
+	counts := NewWordCounts()
+	go parseFiles(files, parseOpts, &cfg, dictionaries, &ignoreList, counts, sourceFiles)
+
+	if options.check {
+		if checkFiles(sourceFiles) {
+			return 0
+		}
+		return 1
+	}
+	if options.staged {
+		var fixable bool
+		sourceFiles, fixable = stagedFixable(sourceFiles, parseOpts.staged)
+		if !fixable {
+			return 1
+		}
+	}
+	if options.fix {
+		written := fixFiles(sourceFiles, &replacements, cfg.General.Backup)
+		if options.staged {
+			if err := stageFiles(written); err != nil {
+				log.Printf("%s: %s\n", InvocationName, err)
+				return 1
+			}
+		}
+		return 0
+	}
 
 	scr := tui.Init(&cfg)
 	defer tui.Quit(scr)
 	tui.Text(scr, 0, 0, "Waiting for highlighter", tcell.StyleDefault)
 	scr.Show()
 
-	checker := NewSpellChecker(scr, speller, &cfg)
-
-	sourceFiles := make(chan sf.SourceFile)
-	go parseFiles(files, &cfg, speller, &ignoreList, sourceFiles)
+	checker := NewSpellChecker(scr, &cfg, &ignoreList, counts, &replacements, &saved)
+	loadSavedChoices(&checker, &cfg)
 
 	allOk := true
 	for sf := range sourceFiles {
@@ -467,21 +925,27 @@ func main() {
 			break
 		}
 	}
-	checker.Finish()
+	written := checker.Finish()
+	saveReplacements(&checker, &cfg)
+	saveChoices(&checker, &cfg)
 
 	scr.Suspend()
 	if allOk {
 		fmt.Println("All files OK")
 	} else if len(options.saveIgnoreList.s) != 0 {
-		additions := make([]string, len(checker.ignore))
-		additions = additions[:0]
-		for word := range checker.ignore {
-			additions = append(additions, word)
-		}
-		if err := appendFileLines(options.saveIgnoreList.s, additions); err != nil {
-			fmt.Printf("Writing ignore list failed: %s", err)
-		} else {
-			fmt.Printf("Saved ignore list to %s", options.saveIgnoreList.s)
+		saveIgnoreList(options.saveIgnoreList.s, &checker, os.Stdout)
+	}
+	if options.staged {
+		// The working tree files matched the index so adding them only adds
+		// the fixes.
+		if err := stageFiles(written); err != nil {
+			log.Printf("%s: %s\n", InvocationName, err)
+			return 1
 		}
 	}
+	if (len(options.commitMsg) != 0 || options.staged) && checker.discardAll {
+		// Aborting the check aborts the commit.
+		return 1
+	}
+	return 0
 }
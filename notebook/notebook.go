@@ -0,0 +1,307 @@
+// Package notebook reads Jupyter notebooks and writes changes to the sources
+// of their cells back without touching the rest of the file.
+package notebook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+// literal is a JSON string literal in the file.
+type literal struct {
+	begin, end int
+	text       string
+}
+
+type Cell struct {
+	// The cell type, "markdown", "code", or "raw".
+	Kind   string
+	Source string
+	// The string literals the source is made of, the source is either a
+	// single string or a list of lines.
+	literals []literal
+	list     bool
+}
+
+// Header returns the line shown above the cell.
+func (self *Cell) Header() string {
+	if self.Kind == "code" {
+		return "# %%"
+	}
+	return fmt.Sprintf("# %%%% [%s]", self.Kind)
+}
+
+// lineCount returns the number of lines in the source.
+func (self *Cell) lineCount() int {
+	return strings.Count(self.Source, "\n") + 1
+}
+
+type Notebook struct {
+	data []byte
+	// The language of the kernel, may be empty.
+	Language string
+	Cells    []Cell
+}
+
+// frame is an array or object the scanner is inside of.
+type frame struct {
+	object bool
+	// The key of the current value in an object.
+	key string
+	// The index of the current value in an array.
+	index     int
+	expectKey bool
+}
+
+// Parse parses a notebook.
+func Parse(data []byte) (*Notebook, error) {
+	var header struct {
+		Metadata struct {
+			Kernelspec struct {
+				Language string `json:"language"`
+			} `json:"kernelspec"`
+			LanguageInfo struct {
+				Name string `json:"name"`
+			} `json:"language_info"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	nb := &Notebook{
+		data:     data,
+		Language: header.Metadata.Kernelspec.Language,
+	}
+	if len(nb.Language) == 0 {
+		nb.Language = header.Metadata.LanguageInfo.Name
+	}
+	if err := nb.scan(); err != nil {
+		return nil, err
+	}
+	return nb, nil
+}
+
+// cell returns the cell with the given index, creating it if needed.
+func (self *Notebook) cell(index int) *Cell {
+	for len(self.Cells) <= index {
+		self.Cells = append(self.Cells, Cell{})
+	}
+	return &self.Cells[index]
+}
+
+// scan finds the types and the source literals of the cells.
+func (self *Notebook) scan() error {
+	dec := json.NewDecoder(bytes.NewReader(self.data))
+	stack := []frame{}
+	// inCell returns whether the current value is the given key of a cell.
+	inCell := func(key string) bool {
+		return len(stack) >= 3 &&
+			stack[0].key == "cells" &&
+			!stack[1].object &&
+			stack[2].object &&
+			stack[2].key == key
+	}
+	for {
+		offset := int(dec.InputOffset())
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var top *frame
+		if len(stack) != 0 {
+			top = &stack[len(stack)-1]
+		}
+		if top != nil && top.object && top.expectKey {
+			if key, ok := token.(string); ok {
+				top.key = key
+				top.expectKey = false
+				continue
+			}
+		}
+		switch value := token.(type) {
+		case json.Delim:
+			if value == '{' || value == '[' {
+				if value == '[' && len(stack) == 3 && inCell("source") {
+					self.cell(stack[1].index).list = true
+				}
+				stack = append(stack, frame{object: value == '{', expectKey: true})
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		case string:
+			if inCell("source") && len(stack) <= 4 {
+				cell := self.cell(stack[1].index)
+				begin := offset + bytes.IndexByte(self.data[offset:], '"')
+				cell.literals = append(cell.literals, literal{begin, int(dec.InputOffset()), value})
+				cell.Source += value
+			} else if inCell("cell_type") && len(stack) == 3 {
+				self.cell(stack[1].index).Kind = value
+			}
+		}
+		// A value in the current container is complete.
+		if len(stack) != 0 {
+			top = &stack[len(stack)-1]
+			if top.object {
+				top.expectKey = true
+			} else {
+				top.index++
+			}
+		}
+	}
+}
+
+// Text returns the text of all cells, each one preceded by its header line.
+func (self *Notebook) Text() string {
+	var builder strings.Builder
+	for i := range self.Cells {
+		builder.WriteString(self.Cells[i].Header())
+		builder.WriteByte('\n')
+		builder.WriteString(self.Cells[i].Source)
+		builder.WriteByte('\n')
+	}
+	return builder.String()
+}
+
+// Original returns the contents of the notebook file.
+func (self *Notebook) Original() []byte {
+	return self.data
+}
+
+// encodeString returns the JSON string literal for s.
+func encodeString(s string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// splitLines splits s after each newline, without an empty last line.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 1 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// restoreEscapes returns a changed line with the escape sequences of the
+// original line, which are not part of the checked text. Sequences inside the
+// changed part of the line are lost.
+func restoreEscapes(original, changed string) string {
+	if !strings.ContainsRune(original, '\x1b') {
+		return changed
+	}
+	// The visible text and the sequences before each of its characters.
+	visible := []rune{}
+	escapes := make(map[int]string)
+	runes := []rune(original)
+	for i := 0; i < len(runes); {
+		if runes[i] == '\x1b' {
+			length, _ := util.ScanEscape(runes[i:])
+			escapes[len(visible)] += string(runes[i : i+length])
+			i += length
+		} else {
+			visible = append(visible, runes[i])
+			i++
+		}
+	}
+	edited := []rune(changed)
+	prefix := 0
+	for prefix < len(visible) && prefix < len(edited) && visible[prefix] == edited[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(visible)-prefix &&
+		suffix < len(edited)-prefix &&
+		visible[len(visible)-1-suffix] == edited[len(edited)-1-suffix] {
+		suffix++
+	}
+	var builder strings.Builder
+	for i := 0; i < prefix; i++ {
+		builder.WriteString(escapes[i])
+		builder.WriteRune(visible[i])
+	}
+	builder.WriteString(escapes[prefix])
+	builder.WriteString(string(edited[prefix : len(edited)-suffix]))
+	for i := len(visible) - suffix; i < len(visible); i++ {
+		if i != prefix {
+			builder.WriteString(escapes[i])
+		}
+		builder.WriteRune(visible[i])
+	}
+	if len(visible) != prefix {
+		builder.WriteString(escapes[len(visible)])
+	}
+	return builder.String()
+}
+
+// Encode returns the notebook file with the cell sources taken from text,
+// which must have the layout returned by Text without any escape sequences.
+// Only the string literals that changed are replaced.
+func (self *Notebook) Encode(text string) ([]byte, error) {
+	lines := strings.Split(text, "\n")
+	type replacement struct {
+		at   literal
+		text string
+	}
+	replacements := []replacement{}
+	for i := range self.Cells {
+		cell := &self.Cells[i]
+		count := cell.lineCount()
+		if len(lines) < count+1 || lines[0] != cell.Header() {
+			return nil, fmt.Errorf("the lines of cell %d do not match the notebook", i+1)
+		}
+		oldLines := strings.Split(cell.Source, "\n")
+		newLines := make([]string, count)
+		for k, line := range lines[1 : count+1] {
+			newLines[k] = restoreEscapes(oldLines[k], line)
+		}
+		source := strings.Join(newLines, "\n")
+		lines = lines[count+1:]
+		if source == cell.Source || len(cell.literals) == 0 {
+			continue
+		}
+		// nbformat stores one line per string but any split is valid, if it's
+		// different all the text goes into the first string. Changes never
+		// add or remove lines so the new lines match the old ones.
+		oldParts := splitLines(cell.Source)
+		newParts := splitLines(source)
+		aligned := cell.list &&
+			len(oldParts) == len(cell.literals) &&
+			len(newParts) == len(cell.literals)
+		for k := 0; aligned && k < len(cell.literals); k++ {
+			aligned = cell.literals[k].text == oldParts[k]
+		}
+		for k, at := range cell.literals {
+			if aligned {
+				if at.text != newParts[k] {
+					replacements = append(replacements, replacement{at, newParts[k]})
+				}
+			} else if k == 0 {
+				replacements = append(replacements, replacement{at, source})
+			} else {
+				replacements = append(replacements, replacement{at, ""})
+			}
+		}
+	}
+	if len(lines) != 1 || len(lines[0]) != 0 {
+		return nil, fmt.Errorf("the text has more lines than the notebook")
+	}
+	var result bytes.Buffer
+	last := 0
+	for _, r := range replacements {
+		result.Write(self.data[last:r.at.begin])
+		result.Write(encodeString(r.text))
+		last = r.at.end
+	}
+	result.Write(self.data[last:])
+	return result.Bytes(), nil
+}
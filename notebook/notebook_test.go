@@ -0,0 +1,105 @@
+package notebook
+
+import (
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {"source": "no"},
+   "source": [
+    "# Tilte\n",
+    "\n",
+    "Some <b>text</b>"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {},
+   "outputs": [{"name": "stdout", "text": ["a\n"]}],
+   "source": "print(\"a\")  # commnet\nx = 1"
+  },
+  {
+   "cell_type": "code",
+   "metadata": {},
+   "outputs": [],
+   "source": []
+  }
+ ],
+ "metadata": {
+  "kernelspec": {"display_name": "Python 3", "language": "python", "name": "python3"}
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+`
+
+func TestParse(t *testing.T) {
+	nb, err := Parse([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nb.Language != "python" {
+		t.Errorf("got language %q", nb.Language)
+	}
+	expected := []Cell{
+		{Kind: "markdown", Source: "# Tilte\n\nSome <b>text</b>"},
+		{Kind: "code", Source: "print(\"a\")  # commnet\nx = 1"},
+		{Kind: "code", Source: ""},
+	}
+	if len(nb.Cells) != len(expected) {
+		t.Fatalf("got %d cells, expected %d", len(nb.Cells), len(expected))
+	}
+	for i, cell := range nb.Cells {
+		if cell.Kind != expected[i].Kind || cell.Source != expected[i].Source {
+			t.Errorf("cell %d: got %s %q, expected %s %q", i, cell.Kind, cell.Source, expected[i].Kind, expected[i].Source)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	nb, err := Parse([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := nb.Encode(nb.Text())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testNotebook {
+		t.Errorf("unchanged text changed the notebook:\n%s", data)
+	}
+	text := strings.NewReplacer("Tilte", "Title", "commnet", "comment").Replace(nb.Text())
+	data, err = nb.Encode(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.NewReplacer(
+		`"# Tilte\n"`, `"# Title\n"`,
+		`# commnet\nx = 1"`, `# comment\nx = 1"`,
+	).Replace(testNotebook)
+	if string(data) != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", data, expected)
+	}
+}
+
+func TestEncodeLineCount(t *testing.T) {
+	nb, err := Parse([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := nb.Text()
+	for _, changed := range []string{
+		strings.Replace(text, "x = 1", "x = 1\ny = 2", 1),
+		strings.Replace(text, "\n\nSome", "\nSome", 1),
+		text + "more\n",
+	} {
+		if _, err := nb.Encode(changed); err == nil {
+			t.Errorf("no error for %q", changed)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+)
+
+// spanTrailing are characters that are not part of a span if they appear at
+// its end, i.e. the period ending a sentence after a URL.
+const spanTrailing = ".,;:!?'\")]}>"
+
+// isSpanOpening returns true if a new span can begin after char, so the URL in
+// `(https://example.com)` is found. This is checked for every character in
+// comments so we don't use a string of them.
+func isSpanOpening(char rune) bool {
+	switch char {
+	case '(', '[', '{', '<', '"', '\'':
+		return true
+	}
+	return false
+}
+
+// spanClass is a kind of text that is not prose, like URLs or hashes.
+type spanClass struct {
+	enabled func(*CfgSkip) bool
+	// Must match the whole span.
+	pattern *regexp.Regexp
+	// Additional check for spans matched by pattern, optional.
+	valid func(string) bool
+}
+
+var spanClasses = []spanClass{
+	{
+		func(skip *CfgSkip) bool { return skip.Urls },
+		regexp.MustCompile(`^(?:[a-zA-Z][a-zA-Z0-9+.-]*://|www\.)\S+$`),
+		nil,
+	},
+	{
+		func(skip *CfgSkip) bool { return skip.Emails },
+		regexp.MustCompile(`^(?:mailto:)?[\w.+-]+@[\w-]+(?:\.[\w-]+)+$`),
+		nil,
+	},
+	{
+		func(skip *CfgSkip) bool { return skip.Paths },
+		regexp.MustCompile(
+			`^(?:(?:~|\.\.?)?/[\w.~+-]+(?:/[\w.~+-]*)*` +
+				`|[\w.-]+(?:/[\w.~+-]+)+(?:\.\w+|/)` +
+				`|[A-Za-z]:\\[\w.~+\\-]*)$`,
+		),
+		nil,
+	},
+	{
+		func(skip *CfgSkip) bool { return skip.Uuids },
+		regexp.MustCompile(`^[[:xdigit:]]{8}(?:-[[:xdigit:]]{4}){3}-[[:xdigit:]]{12}$`),
+		nil,
+	},
+	{
+		func(skip *CfgSkip) bool { return skip.Hex },
+		regexp.MustCompile(`^(?:0[xX][[:xdigit:]]+|[[:xdigit:]]{7,})$`),
+		// Words like `deadbeef` or `defaced` are hex too.
+		func(span string) bool {
+			return strings.HasPrefix(span, "0x") ||
+				strings.HasPrefix(span, "0X") ||
+				strings.IndexFunc(span, unicode.IsDigit) >= 0
+		},
+	},
+	{
+		func(skip *CfgSkip) bool { return skip.Base64 },
+		regexp.MustCompile(`^[A-Za-z0-9+/_-]{16,}={0,2}$`),
+		// Long identifiers are not base64, unless they mix all character
+		// classes.
+		func(span string) bool {
+			return strings.HasSuffix(span, "=") ||
+				(strings.IndexFunc(span, unicode.IsDigit) >= 0 &&
+					strings.IndexFunc(span, unicode.IsUpper) >= 0 &&
+					strings.IndexFunc(span, unicode.IsLower) >= 0)
+		},
+	},
+}
+
+// anySpanClass returns whether any span class is enabled.
+func anySpanClass(skip *CfgSkip) bool {
+	for _, class := range spanClasses {
+		if class.enabled(skip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimSpan returns the length of span without trailing punctuation.
+func trimSpan(span []rune) int {
+	length := len(span)
+	for length > 0 && strings.ContainsRune(spanTrailing, span[length-1]) {
+		length--
+	}
+	return length
+}
+
+// classifySpan returns whether span belongs to any of the enabled classes.
+func classifySpan(span string, skip *CfgSkip) bool {
+	for _, class := range spanClasses {
+		if class.enabled(skip) &&
+			class.pattern.MatchString(span) &&
+			(class.valid == nil || class.valid(span)) {
+			return true
+		}
+	}
+	return false
+}
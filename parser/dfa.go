@@ -1,69 +1,196 @@
 package parser
 
-import (
-	"github.com/JaMo42/spellcheck_comments/util"
-)
-
 // State identifier
 type State int
 
-// Token that's grown as the DFA processes more bytes.
-type dfaToken = uint64
+type Transition struct {
+	token   []rune
+	toState State
+	// Whether the token only matches at the beginning of a line.
+	lineStart bool
+}
+
+// trieNode is a node in the token trie of a state. Each node has a
+// transition if a token ends at it.
+type trieNode struct {
+	children   map[rune]*trieNode
+	transition *Transition
+}
 
-type maskedToken struct {
-	Data   dfaToken
-	Mask   uint64
-	Length int
+func newTrieNode() *trieNode {
+	return &trieNode{children: map[rune]*trieNode{}}
 }
 
-func newMaskedToken(text string) maskedToken {
-	length := len([]rune(text))
-	mask := (uint64(1) << (len(text) * 8)) - 1
-	return maskedToken{
-		Data:   util.String2Int(text),
-		Mask:   mask,
-		Length: length,
+// insert adds the token of the given transition to the trie. If the token was
+// already added the first transition is kept.
+func (self *trieNode) insert(trans *Transition) {
+	node := self
+	for _, c := range trans.token {
+		child, ok := node.children[c]
+		if !ok {
+			child = newTrieNode()
+			node.children[c] = child
+		}
+		node = child
+	}
+	if node.transition == nil {
+		node.transition = trans
 	}
 }
 
-func (self maskedToken) Eq(token dfaToken) bool {
-	// We always check the low bytes of the tokens, this works for us
-	// since we reset the token when leaving a comment and don't add
-	// anything to it in code so for the starting tokens the DFAs token
-	// will only contain the data we want and for ending tokens it works
-	// naturally.
-	return self.Data == uint64(token)&self.Mask
+// longestMatch returns the transition with the longest token that is a prefix
+// of input, or nil if no token matches.
+func (self *trieNode) longestMatch(input []rune, atLineStart bool) *Transition {
+	var match *Transition
+	node := self
+	for _, c := range input {
+		node = node.children[c]
+		if node == nil {
+			break
+		}
+		if node.transition != nil && (atLineStart || !node.transition.lineStart) {
+			match = node.transition
+		}
+	}
+	return match
 }
 
-type Transition struct {
-	token   maskedToken
-	toState State
+// runeSet is a set of runes, ASCII runes are looked up in a bit set as they
+// make up most of the input.
+type runeSet struct {
+	ascii [2]uint64
+	other map[rune]bool
+}
+
+func (self *runeSet) add(r rune) {
+	if r < 128 {
+		self.ascii[r/64] |= 1 << (r % 64)
+		return
+	}
+	if self.other == nil {
+		self.other = map[rune]bool{}
+	}
+	self.other[r] = true
 }
 
-func newTransition(str string, toState State) Transition {
-	return Transition{newMaskedToken(str), toState}
+func (self *runeSet) contains(r rune) bool {
+	if r < 128 {
+		return self.ascii[r/64]&(1<<(r%64)) != 0
+	}
+	return self.other[r]
+}
+
+// maxEscapeLength is the maximum length of an escape sequence in a character
+// literal, excluding the escape character. This fits `\u{10FFFF}`.
+const maxEscapeLength = 10
+
+// charLiteral describes character literals, they are scanned by hand since
+// they can't be fixed tokens.
+type charLiteral struct {
+	quote     []rune
+	escape    []rune
+	maxLength int
+}
+
+// length returns the length of the character literal at the beginning of
+// input, or 0 if there is none. An escape sequence counts as 1 character.
+func (self *charLiteral) length(input []rune) int {
+	if !hasPrefix(input, self.quote) {
+		return 0
+	}
+	// How many more runes belong to the last escape sequence, these are only
+	// needed once the literal has maxLength characters.
+	escapeLeft := 0
+	count := 0
+	for i := len(self.quote); i < len(input); {
+		c := input[i]
+		if c == self.quote[0] {
+			if count == 0 || !hasPrefix(input[i:], self.quote) {
+				return 0
+			}
+			return i + len(self.quote)
+		} else if c == '\n' {
+			return 0
+		} else if len(self.escape) != 0 && c == self.escape[0] {
+			if !hasPrefix(input[i:], self.escape) {
+				return 0
+			}
+			// The escaped rune may be the quote.
+			i += len(self.escape)
+			if i == len(input) || input[i] == '\n' {
+				return 0
+			}
+			escapeLeft = maxEscapeLength - 1
+		} else if count == self.maxLength && escapeLeft != 0 {
+			escapeLeft--
+			i++
+			continue
+		}
+		count++
+		if count > self.maxLength {
+			return 0
+		}
+		i++
+	}
+	return 0
 }
 
 type DfaState struct {
 	id   State
 	info int
-	// Set of characters this state wants to accept
-	useChars    []rune
-	transitions []Transition
-	// Note: we expect a small number of relevant characters and states
-	//       so we use arrays for these instead of maps.
+	// The runes any token of this state can begin with, so we don't need to
+	// look further at most positions.
+	first runeSet
+	// Note: we expect a small number of states so we use an array for these
+	//       but the tokens are stored in a trie as it's checked for every
+	//       character.
+	tokens *trieNode
+	// Regex transitions by their possible first runes.
+	regexes     map[rune][]*regexTransition
+	chars       []charLiteral
 	isRecursive bool
-	descent     maskedToken
-	ascent      maskedToken
+	descent     recursionToken
+	ascent      recursionToken
+}
+
+// recursionToken is a token changing the recursion depth of a state, either
+// fixed text or a regex.
+type recursionToken struct {
+	text  []rune
+	regex *regexTransition
+}
+
+// addFirstRunes adds the runes the token can begin with to set.
+func (self *recursionToken) addFirstRunes(set *runeSet) {
+	if self.regex != nil {
+		for _, c := range self.regex.firstRunes() {
+			set.add(c)
+		}
+	} else if len(self.text) != 0 {
+		set.add(self.text[0])
+	}
+}
+
+// match returns the length of the token at the beginning of input, or 0 if it
+// does not match.
+func (self *recursionToken) match(input []rune, atLineStart bool) int {
+	if self.regex != nil {
+		length, _ := self.regex.match(input, atLineStart)
+		return length
+	}
+	if len(self.text) != 0 && hasPrefix(input, self.text) {
+		return len(self.text)
+	}
+	return 0
 }
 
 func newDfaState(id State, info int) *DfaState {
 	state := new(DfaState)
 	*state = DfaState{
-		id:          id,
-		info:        info,
-		useChars:    []rune{},
-		transitions: []Transition{},
+		id:      id,
+		info:    info,
+		tokens:  newTrieNode(),
+		regexes: map[rune][]*regexTransition{},
 	}
 	return state
 }
@@ -73,21 +200,91 @@ func newDfaState(id State, info int) *DfaState {
 // not count as state changes.
 func (self *DfaState) MakeRecursive(descent, ascent string) {
 	self.isRecursive = true
-	self.descent = newMaskedToken(descent)
-	self.ascent = newMaskedToken(ascent)
+	self.descent = recursionToken{text: []rune(descent)}
+	self.ascent = recursionToken{text: []rune(ascent)}
+	self.descent.addFirstRunes(&self.first)
+	self.ascent.addFirstRunes(&self.first)
+}
+
+// MakeRegexRecursive is like MakeRecursive but descent and ascent are regular
+// expressions.
+func (self *DfaState) MakeRegexRecursive(descent, ascent string) {
+	self.isRecursive = true
+	self.descent = recursionToken{regex: newRegexTransition(descent, self.id, nil)}
+	self.ascent = recursionToken{regex: newRegexTransition(ascent, self.id, nil)}
+	self.descent.addFirstRunes(&self.first)
+	self.ascent.addFirstRunes(&self.first)
 }
 
 func (self *DfaState) Id() State {
 	return self.id
 }
 
+// AddTransition adds a transition to another state. Tokens can have any
+// length, if multiple tokens match the longest one is used.
 func (self *DfaState) AddTransition(token string, toState State) {
-	for _, c := range token {
-		if !util.Contains(self.useChars, c) {
-			self.useChars = append(self.useChars, c)
+	self.addTransition(&Transition{[]rune(token), toState, false})
+}
+
+// AddLineStartTransition adds a transition whose token only matches at the
+// beginning of a line, ignoring indentation.
+func (self *DfaState) AddLineStartTransition(token string, toState State) {
+	self.addTransition(&Transition{[]rune(token), toState, true})
+}
+
+func (self *DfaState) addTransition(trans *Transition) {
+	if len(trans.token) != 0 {
+		self.first.add(trans.token[0])
+	}
+	self.tokens.insert(trans)
+}
+
+// AddRegexTransition adds a transition that is taken if the regex matches.
+// The templates are expanded with the groups of the match and added as
+// transitions until the state is left again.
+func (self *DfaState) AddRegexTransition(pattern string, toState State, templates ...TokenTemplate) {
+	trans := newRegexTransition(pattern, toState, templates)
+	for _, c := range trans.firstRunes() {
+		self.first.add(c)
+		self.regexes[c] = append(self.regexes[c], trans)
+	}
+}
+
+// AddCharLiteral adds character literals to the state, they don't change the
+// state. The literal contains at most maxLength characters.
+func (self *DfaState) AddCharLiteral(quote, escape string, maxLength int) {
+	literal := charLiteral{[]rune(quote), []rune(escape), maxLength}
+	self.first.add(literal.quote[0])
+	self.chars = append(self.chars, literal)
+}
+
+// matchChars returns the length of the longest character literal at the
+// beginning of input.
+func (self *DfaState) matchChars(input []rune) int {
+	longest := 0
+	for i := range self.chars {
+		if length := self.chars[i].length(input); length > longest {
+			longest = length
+		}
+	}
+	return longest
+}
+
+// matchRegexes returns the longest match of the regex transitions at the
+// beginning of input.
+func (self *DfaState) matchRegexes(input []rune, atLineStart bool) (*regexTransition, int, []TokenTemplate) {
+	var best *regexTransition
+	var bestLength int
+	var bestTokens []TokenTemplate
+	if len(self.regexes) == 0 || len(input) == 0 {
+		return nil, 0, nil
+	}
+	for _, trans := range self.regexes[input[0]] {
+		if length, tokens := trans.match(input, atLineStart); length > bestLength {
+			best, bestLength, bestTokens = trans, length, tokens
 		}
 	}
-	self.transitions = append(self.transitions, newTransition(token, toState))
+	return best, bestLength, bestTokens
 }
 
 type Dfa struct {
@@ -95,15 +292,16 @@ type Dfa struct {
 	// stays valid even when this slice is reallocated
 	states         []*DfaState
 	current        State
-	token          dfaToken
 	recursionDepth int
+	// Transitions created from templates, these only exist until the state
+	// that was entered through the regex transition is left.
+	dynamic *trieNode
 }
 
 func NewDfa() Dfa {
 	return Dfa{
 		states:  []*DfaState{},
 		current: 0,
-		token:   0,
 	}
 }
 
@@ -117,45 +315,140 @@ func (self *Dfa) CurrentState() *DfaState {
 	return self.states[int(self.current)]
 }
 
-// Process processes one character of input. Returns whether the current state
-// changed and the length in runes of the token that caused the state change.
-func (self *Dfa) Process(c rune) (bool, int) {
-	currentState := self.CurrentState()
-	if !util.Contains(currentState.useChars, c) {
-		self.token = 0
-		return false, 0
+// hasPrefix returns true if s starts with prefix.
+func hasPrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
 	}
-	if c < 0x80 {
-		self.token <<= 8
-		self.token |= uint64(c)
-	} else {
-		str := string(c)
-		self.token <<= 8 * len(str) // len gives length in bytes
-		self.token |= util.String2Int(str)
+	for i, c := range prefix {
+		if s[i] != c {
+			return false
+		}
 	}
+	return true
+}
+
+// dfaMatch is a token found at the current position of the DFA.
+type dfaMatch struct {
+	length int
+	// The change of the recursion depth, tokens changing it don't change the
+	// state.
+	depthChange int
+	trans       *Transition
+	regex       *regexTransition
+	tokens      []TokenTemplate
+	// Whether the token is a character literal, which stays in the state.
+	literal bool
+}
+
+// mayMatch returns false if no token of the current state begins with the
+// first rune of input.
+func (self *Dfa) mayMatch(input []rune) bool {
+	return len(input) != 0 &&
+		(self.states[self.current].first.contains(input[0]) ||
+			self.dynamic != nil && self.dynamic.children[input[0]] != nil)
+}
+
+// match finds the token at the beginning of input without changing the state.
+func (self *Dfa) match(input []rune, atLineStart bool) dfaMatch {
+	currentState := self.CurrentState()
 	if currentState.isRecursive {
-		if currentState.descent.Eq(self.token) {
-			self.recursionDepth++
-			self.token = 0
-			return false, 0
-		} else if self.recursionDepth != 0 && currentState.ascent.Eq(self.token) {
-			self.recursionDepth--
-			self.token = 0
-			return false, 0
+		if length := currentState.descent.match(input, atLineStart); length != 0 {
+			return dfaMatch{length: length, depthChange: 1}
+		} else if self.recursionDepth != 0 {
+			if length := currentState.ascent.match(input, atLineStart); length != 0 {
+				return dfaMatch{length: length, depthChange: -1}
+			}
 		}
 	}
-	for _, trans := range currentState.transitions {
-		if trans.token.Eq(self.token) {
-			self.current = trans.toState
-			self.token = 0
-			return true, trans.token.Length
+	trans := currentState.tokens.longestMatch(input, atLineStart)
+	if self.dynamic != nil {
+		dynamic := self.dynamic.longestMatch(input, atLineStart)
+		if dynamic != nil && (trans == nil || len(dynamic.token) >= len(trans.token)) {
+			trans = dynamic
 		}
 	}
-	return false, 0
+	length := 0
+	if trans != nil {
+		length = len(trans.token)
+	}
+	regex, regexLength, tokens := currentState.matchRegexes(input, atLineStart)
+	if regex != nil && regexLength > length && self.recursionDepth != 0 && regex.toState != self.current {
+		// Only the ascent token can leave a nested state.
+		regex = nil
+	}
+	if regex != nil && regexLength > length {
+		length = regexLength
+	} else {
+		regex = nil
+	}
+	if literal := currentState.matchChars(input); literal > length {
+		return dfaMatch{length: literal, literal: true}
+	}
+	if regex != nil {
+		return dfaMatch{length: length, regex: regex, tokens: tokens}
+	}
+	if trans == nil {
+		return dfaMatch{}
+	}
+	if self.recursionDepth != 0 && trans.toState != self.current {
+		// Only the ascent token can leave a nested state.
+		return dfaMatch{}
+	}
+	return dfaMatch{length: length, trans: trans}
+}
+
+// Process checks for a token at the beginning of input. Returns whether the
+// current state changed and the length in runes of the matched token, which
+// is 0 if there is no token. Tokens that change the recursion depth have a
+// length but do not change the state. atLineStart is whether the input is at
+// the beginning of a line.
+// If multiple tokens match the longest one is used, fixed tokens take
+// precedence over regex tokens of the same length and tokens created from
+// templates take precedence over fixed tokens.
+func (self *Dfa) Process(input []rune, atLineStart bool) (bool, int) {
+	if !self.mayMatch(input) {
+		return false, 0
+	}
+	match := self.match(input, atLineStart)
+	if match.depthChange != 0 {
+		self.recursionDepth += match.depthChange
+		return false, match.length
+	}
+	if match.literal {
+		return true, match.length
+	}
+	if match.regex != nil {
+		self.current = match.regex.toState
+		self.dynamic = newTrieNode()
+		for _, token := range match.tokens {
+			text, lineStart := parseTemplateText(token.Text)
+			self.dynamic.insert(&Transition{[]rune(text), token.ToState, lineStart})
+		}
+		return true, match.length
+	}
+	if match.trans == nil {
+		return false, 0
+	}
+	if match.trans.toState != self.current {
+		self.dynamic = nil
+	}
+	self.current = match.trans.toState
+	return true, match.length
+}
+
+// Peek returns the length of the token at the beginning of input like
+// Process, without changing the state.
+func (self *Dfa) Peek(input []rune, atLineStart bool) int {
+	if !self.mayMatch(input) {
+		return 0
+	}
+	return self.match(input, atLineStart).length
 }
 
-// ForceState sets the current state and resets the token.
-func (self *Dfa) ForceState(id State) {
-	self.current = id
-	self.token = 0
+// Reset puts the DFA back into its initial state.
+func (self *Dfa) Reset() {
+	self.current = 0
+	self.recursionDepth = 0
+	self.dynamic = nil
 }
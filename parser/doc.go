@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+// docStringKeywords are the statements whose first statement may be a doc
+// string.
+var docStringKeywords = []string{"def", "class", "async"}
+
+// docState contains the rules and the state needed to detect doc comments.
+type docState struct {
+	// Tokens that always begin doc comments.
+	tokens []string
+	// Block comment tokens that begin doc strings in doc string position.
+	blocks  []string
+	strings bool
+	// Keywords of declarations that make the comments directly above them
+	// doc comments.
+	before     []string
+	lineTokens []string
+	// The last code character that is not whitespace.
+	lastCode rune
+	// The first word of the statement that contains lastCode.
+	lastKeyword string
+	keyword     []rune
+	keywordDone bool
+	depth       int
+}
+
+func newDocState(style CommentStyle) docState {
+	return docState{
+		tokens:     append(util.Copy(style.DocLine), style.DocBlockBegin...),
+		blocks:     style.BlockBegin,
+		strings:    style.DocStrings,
+		before:     style.DocBefore,
+		lineTokens: util.Map(append(util.Copy(style.Line), style.DocLine...), stripLineStart),
+	}
+}
+
+// trackCode tracks a character of code, this is needed to detect doc strings.
+func (self *docState) trackCode(c rune) {
+	if !self.strings {
+		return
+	}
+	switch c {
+	case '\n':
+		if self.depth == 0 {
+			self.keyword = self.keyword[:0]
+			self.keywordDone = false
+		}
+		return
+	case ' ', '\t', '\r':
+		self.keywordDone = self.keywordDone || len(self.keyword) != 0
+		return
+	case '(', '[', '{':
+		self.depth++
+	case ')', ']', '}':
+		if self.depth > 0 {
+			self.depth--
+		}
+	}
+	if !self.keywordDone {
+		if unicode.IsLetter(c) || c == '_' {
+			self.keyword = append(self.keyword, c)
+		} else {
+			self.keywordDone = true
+		}
+	}
+	self.lastCode = c
+	self.lastKeyword = string(self.keyword)
+}
+
+// atDocStringPosition returns true if a string at the current position is the
+// first statement of a module, function, or class.
+func (self *docState) atDocStringPosition() bool {
+	return self.lastCode == 0 ||
+		(self.lastCode == ':' && util.Contains(docStringKeywords, self.lastKeyword))
+}
+
+// stripEscapes removes escape sequences from the text.
+func stripEscapes(text []rune) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] == '\x1b' {
+			length, _ := util.ScanEscape(text[i:])
+			i += util.Max(length, 1)
+			continue
+		}
+		sb.WriteRune(text[i])
+		i++
+	}
+	return sb.String()
+}
+
+// declarationFollows returns true if the comment beginning before rest is
+// directly followed by a declaration, only separated by other line comments.
+func (self *docState) declarationFollows(rest []rune) bool {
+	// Skip the rest of the line containing the comment.
+	end, ok := util.Position(rest, '\n')
+	if !ok {
+		return false
+	}
+	rest = rest[end+1:]
+lines:
+	for len(rest) != 0 {
+		line := rest
+		if end, ok := util.Position(rest, '\n'); ok {
+			line, rest = rest[:end], rest[end+1:]
+		} else {
+			rest = nil
+		}
+		text := strings.TrimSpace(strings.TrimRight(stripEscapes(line), string(eofRune)))
+		if len(text) == 0 {
+			return false
+		}
+		for _, token := range self.lineTokens {
+			if strings.HasPrefix(text, token) {
+				continue lines
+			}
+		}
+		keyword := text
+		if end := strings.IndexFunc(text, func(c rune) bool {
+			return !unicode.IsLetter(c) && c != '_'
+		}); end >= 0 {
+			keyword = text[:end]
+		}
+		return util.Contains(self.before, keyword)
+	}
+	return false
+}
+
+// isDocComment returns true if the comment beginning with token is a doc
+// comment. rest is the source following the token and atLineStart whether the
+// token is at the beginning of a line.
+func (self *docState) isDocComment(token string, rest []rune, atLineStart bool) bool {
+	if util.Contains(self.tokens, token) {
+		return true
+	}
+	if self.strings && util.Contains(self.blocks, token) && self.atDocStringPosition() {
+		return true
+	}
+	return len(self.before) != 0 && atLineStart && self.declarationFollows(rest)
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"regexp"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+)
+
+// lexStateEmbedded is the base of the state infos for embedded languages, the
+// index of the language is added to it.
+const lexStateEmbedded = 1 << 16
+
+// embeddedLanguage is an embedded language with its begin compiled.
+type embeddedLanguage struct {
+	begin    *regexp.Regexp
+	end      string
+	language string
+}
+
+// embeddedState contains the embedded languages of a style and the state of
+// the host language while inside of one.
+type embeddedState struct {
+	languages []embeddedLanguage
+	// Returns the style for a language name.
+	resolve func(string) CommentStyle
+	dfas    map[string]Dfa
+	styles  map[string]CommentStyle
+	// The end token of the current region, nil if not inside one.
+	end          []rune
+	endLineStart bool
+	hostDfa      Dfa
+	hostDoc      docState
+}
+
+func newEmbeddedState(style CommentStyle) embeddedState {
+	languages := []embeddedLanguage{}
+	for _, embedded := range style.Embedded {
+		languages = append(languages, embeddedLanguage{
+			regexp.MustCompile(embedded.Begin),
+			embedded.End,
+			embedded.Language,
+		})
+	}
+	return embeddedState{
+		languages: languages,
+		resolve:   func(string) CommentStyle { return CommentStyle{} },
+		dfas:      map[string]Dfa{},
+		styles:    map[string]CommentStyle{},
+	}
+}
+
+// SetLanguages sets the function that returns the style for the name of an
+// embedded language. Without it embedded languages have no comments.
+func (self *Lexer) SetLanguages(resolve func(language string) CommentStyle) {
+	self.embedded.resolve = resolve
+}
+
+// language returns the style and DFA for a language.
+func (self *embeddedState) language(name string) (CommentStyle, Dfa) {
+	if dfa, ok := self.dfas[name]; ok {
+		return self.styles[name], dfa
+	}
+	style := self.resolve(name)
+	// Only one level of embedding is supported.
+	style.Embedded = nil
+	dfa := buildDfa(style)
+	self.styles[name] = style
+	self.dfas[name] = dfa
+	return style, dfa
+}
+
+// atEnd returns whether the current embedded region ends at the beginning of
+// input.
+func (self *embeddedState) atEnd(input []rune, atLineStart bool) bool {
+	return self.end != nil &&
+		(atLineStart || !self.endLineStart) &&
+		hasPrefix(input, self.end)
+}
+
+// enterEmbedded switches to the language with the given index, its begin
+// token is the last used text. fromComment is whether the token was found in a
+// comment, as in prose.
+func (self *Lexer) enterEmbedded(index int, token []rune, fromComment bool) {
+	addToken := func(t Token) {
+		self.nextTokens = append(self.nextTokens, t)
+	}
+	self.createToken(TokenKind.Code).Then(addToken)
+	if fromComment {
+		addToken(self.createMarker(TokenKind.CommentEnd))
+		self.markup.endComment()
+	}
+	language := &self.embedded.languages[index]
+	text := string(token)
+	match := language.begin.FindStringSubmatchIndex(text)
+	name := string(language.begin.ExpandString(nil, language.language, text, match))
+	end := string(language.begin.ExpandString(nil, language.end, text, match))
+	end, self.embedded.endLineStart = parseTemplateText(end)
+	self.embedded.end = []rune(end)
+	self.embedded.hostDfa = self.dfa
+	self.embedded.hostDoc = self.doc
+	style, dfa := self.embedded.language(name)
+	self.dfa = dfa
+	self.doc = newDocState(style)
+	self.enterState()
+}
+
+// leaveEmbedded switches back to the host language, the end token of the
+// embedded region is at the beginning of the unused text.
+func (self *Lexer) leaveEmbedded() {
+	addToken := func(t Token) {
+		self.nextTokens = append(self.nextTokens, t)
+	}
+	if self.state == lexStateInComment {
+		self.finishWord()
+		self.createToken(TokenKind.Code).Then(addToken)
+		addToken(self.createMarker(TokenKind.CommentEnd))
+		self.markup.endComment()
+	}
+	self.used += len(self.embedded.end)
+	self.createToken(TokenKind.Code).Then(addToken)
+	self.embedded.end = nil
+	self.dfa = self.embedded.hostDfa
+	self.doc = self.embedded.hostDoc
+	self.dfa.Reset()
+	self.atLineStart = false
+	self.enterState()
+}
+
+// enterState sets the lexer state from the current state of a new DFA.
+func (self *Lexer) enterState() {
+	self.state = self.dfa.CurrentState().info
+	if self.state == lexStateInComment {
+		// Prose begins in a comment.
+		self.nextTokens = append(self.nextTokens, self.createMarker(TokenKind.CommentBegin))
+		self.markup.beginLine(self.column())
+		self.spanStart = true
+	}
+}
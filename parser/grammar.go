@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+	"github.com/JaMo42/spellcheck_comments/tui"
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+// grammarChecker finds repeated and confusable words, and words spelled in
+// the other variant of English in comments.
+type grammarChecker struct {
+	repeated bool
+	variants SpellingVariants
+	// Maps lower case words to their group of confusables.
+	confusables map[string][]string
+	// Runes that may appear between repeated words besides whitespace, these
+	// are the runes of the comment delimiters so repeated words are also found
+	// across comment lines.
+	delimiters string
+	// The lower case previous word, empty if something other than whitespace
+	// and delimiters came after it.
+	last string
+}
+
+func newGrammarChecker(cfg *CfgGrammar, style CommentStyle) grammarChecker {
+	confusables := map[string][]string{}
+	for _, group := range cfg.Confusables {
+		for _, word := range group {
+			confusables[strings.ToLower(word)] = group
+		}
+	}
+	tokens := [][]string{
+		style.Line, style.DocLine, style.DocBlockBegin, style.DocBlockEnd, {"*"},
+	}
+	if !style.BlockRegex {
+		tokens = append(tokens, style.BlockBegin, style.BlockEnd)
+	}
+	var delimiters strings.Builder
+	for _, list := range tokens {
+		for _, token := range list {
+			delimiters.WriteString(stripLineStart(token))
+		}
+	}
+	// The locale is validated when loading the config.
+	variants, _ := NewSpellingVariants(cfg.Locale)
+	return grammarChecker{
+		repeated:    cfg.RepeatedWords,
+		variants:    variants,
+		confusables: confusables,
+		delimiters:  delimiters.String(),
+	}
+}
+
+// separator processes text between words.
+func (self *grammarChecker) separator(text string) {
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !strings.ContainsRune(self.delimiters, r) {
+			self.last = ""
+			return
+		}
+	}
+}
+
+// splitLetters splits text between words at single letters. These are not
+// words for spell checking but they can be repeated ("a a").
+func splitLetters(text string) []string {
+	runes := []rune(text)
+	isPartOfWord := func(i int) bool {
+		return i >= 0 && i < len(runes) && (isWordChar(runes[i]) || unicode.IsDigit(runes[i]))
+	}
+	parts := []string{}
+	start := 0
+	for i, r := range runes {
+		if !unicode.IsLetter(r) || isPartOfWord(i-1) || isPartOfWord(i+1) {
+			continue
+		}
+		if i > start {
+			parts = append(parts, string(runes[start:i]))
+		}
+		parts = append(parts, string(r))
+		start = i + 1
+	}
+	if start < len(runes) {
+		parts = append(parts, string(runes[start:]))
+	}
+	return parts
+}
+
+// checksLetters returns true if single letters in the text between words of
+// a comment need to be checked, see splitLetters.
+func (self *grammarChecker) checksLetters() bool {
+	return self.repeated
+}
+
+// reset forgets the previous word.
+func (self *grammarChecker) reset() {
+	self.last = ""
+}
+
+// check checks the word at the given index and returns the finding for it, if
+// there is anything wrong with it.
+func (self *grammarChecker) check(word string, index tui.SliceIndex) Optional[sf.Word] {
+	lower := strings.ToLower(word)
+	repeated := self.repeated && lower == self.last
+	self.last = lower
+	if repeated {
+		return Some(sf.NewFinding(sf.WordKind.Repeated, word, index, nil, "repeated word"))
+	}
+	if preferred := self.variants.Preferred(word); preferred.IsSome() {
+		return Some(sf.NewFinding(
+			sf.WordKind.Locale,
+			word,
+			index,
+			[]string{preferred.Unwrap()},
+			self.variants.Reason(),
+		))
+	}
+	group, ok := self.confusables[lower]
+	if !ok {
+		return None[sf.Word]()
+	}
+	alternatives := []string{}
+	for _, alternative := range group {
+		if strings.ToLower(alternative) != lower {
+			alternatives = append(alternatives, util.MatchCase(alternative, word))
+		}
+	}
+	reason := fmt.Sprintf("often confused with %s", strings.Join(alternatives, ", "))
+	return Some(sf.NewFinding(sf.WordKind.Confusable, word, index, alternatives, reason))
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+	"github.com/JaMo42/spellcheck_comments/tui"
+)
+
+// findings returns the words with findings in source, confusable words are
+// followed by their alternatives.
+func findings(source string, style CommentStyle, cfg *CfgGrammar) []string {
+	grammar := newGrammarChecker(cfg, style)
+	lexer := NewLexer(source, style)
+	result := []string{}
+	check := func(word string) {
+		grammar.check(word, tui.SliceIndex{}).Then(func(finding sf.Word) {
+			if finding.Kind == sf.WordKind.Repeated {
+				result = append(result, "repeated "+word)
+			} else {
+				result = append(result, word)
+				result = append(result, finding.Alternatives...)
+			}
+		})
+	}
+	inComment := false
+	for {
+		token := lexer.Next()
+		switch token.Kind() {
+		case TokenKind.CommentBegin:
+			inComment = true
+		case TokenKind.CommentEnd:
+			inComment = false
+		case TokenKind.Code:
+			if !inComment || !grammar.checksLetters() {
+				grammar.separator(token.text)
+				break
+			}
+			for _, part := range splitLetters(token.text) {
+				if isLetter(part) {
+					check(part)
+				} else {
+					grammar.separator(part)
+				}
+			}
+		case TokenKind.CommentWord:
+			before, word, after := TrimSymbols(token.text)
+			grammar.separator(before)
+			check(word)
+			grammar.separator(after)
+		case TokenKind.EOF:
+			return result
+		}
+	}
+}
+
+func TestGrammar(t *testing.T) {
+	style := CommentStyle{
+		Line:       []string{"//"},
+		BlockBegin: []string{"/*"},
+		BlockEnd:   []string{"*/"},
+	}
+	cfg := CfgGrammar{
+		RepeatedWords: true,
+		Confusables:   [][]string{{"its", "it's"}, {"then", "than"}},
+	}
+	tests := []struct {
+		source   string
+		findings []string
+	}{
+		{"// the the cat", []string{"repeated the"}},
+		{"// a a cat", []string{"repeated a"}},
+		{"// to\n  // To", []string{"repeated To"}},
+		{"/* the\n * the */", []string{"repeated the"}},
+		{"// the. The", []string{}},
+		{"// the\nx = 1 // the", []string{}},
+		{"// the `x` the", []string{}},
+		{"// Its better then", []string{"Its", "It's", "then", "than"}},
+	}
+	for _, test := range tests {
+		if got := findings(test.source, style, &cfg); !reflect.DeepEqual(got, test.findings) {
+			t.Errorf("%q: got %q, expected %q", test.source, got, test.findings)
+		}
+	}
+	cfg.RepeatedWords = false
+	if got := findings("// the the", style, &cfg); len(got) != 0 {
+		t.Errorf("got %q with repeated words disabled", got)
+	}
+	cfg.Locale = "en_US"
+	expected := []string{"Colour", "Color", "initialised", "initialized", "centring", "centering"}
+	if got := findings("// Colour color initialised centring", style, &cfg); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	cfg.Locale = "en-GB"
+	expected = []string{"BEHAVIOR", "BEHAVIOUR"}
+	if got := findings("// BEHAVIOR behaviour", style, &cfg); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
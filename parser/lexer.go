@@ -13,21 +13,21 @@ const (
 	eofRune            = rune(0)
 	eofStateInfo       = -1
 	lexStateInCode int = iota
-	lexStateInEscape
 	lexStateInComment
 	lexStateInString
+	lexStateInSkip
 )
 
 func LexerStateInfoName(info int) string {
 	switch info {
 	case lexStateInCode:
 		return "InCode"
-	case lexStateInEscape:
-		return "InEscape"
 	case lexStateInComment:
 		return "InComment"
 	case lexStateInString:
 		return "InString"
+	case lexStateInSkip:
+		return "InSkip"
 	case eofStateInfo:
 		return "EOF"
 	default:
@@ -50,9 +50,22 @@ var TokenKind = struct {
 	CommentEnd   TokenKindType
 	Newline      TokenKindType
 	EOF          TokenKindType
-}{0, 1, 2, 3, 4, 6, 7}
+	// A comment word selecting the dictionaries, see dictionaryPragma.
+	Pragma TokenKindType
+	// Doc is a flag set on CommentBegin tokens of doc comments.
+	Doc TokenKindType
+	// Literal is a flag set on Code tokens of string and character literals,
+	// see Lexer.SetMarkLiterals.
+	Literal TokenKindType
+}{0, 1, 2, 3, 4, 6, 7, 5, 8, 16}
 
 func LexerTokenKindName(kind TokenKindType) string {
+	if kind&TokenKind.Doc != 0 {
+		return LexerTokenKindName(kind&^TokenKind.Doc) + "|Doc"
+	}
+	if kind&TokenKind.Literal != 0 {
+		return LexerTokenKindName(kind&^TokenKind.Literal) + "|Literal"
+	}
 	switch kind {
 	case TokenKind.Code:
 		return "Code"
@@ -68,6 +81,8 @@ func LexerTokenKindName(kind TokenKindType) string {
 		return "Newline"
 	case TokenKind.EOF:
 		return "EOF"
+	case TokenKind.Pragma:
+		return "Pragma"
 	}
 	panic("not a token kind")
 }
@@ -87,8 +102,20 @@ func (self *Token) String() string {
 	)
 }
 
+// Kind returns the kind of the token without flags.
 func (self *Token) Kind() TokenKindType {
-	return self.kind
+	return self.kind &^ (TokenKind.Doc | TokenKind.Literal)
+}
+
+// IsDoc returns true if the token begins a doc comment.
+func (self *Token) IsDoc() bool {
+	return self.kind&TokenKind.Doc != 0
+}
+
+// IsLiteral returns true if the token is (part of) a string or character
+// literal.
+func (self *Token) IsLiteral() bool {
+	return self.kind&TokenKind.Literal != 0
 }
 
 func (self *Token) Text() string {
@@ -100,78 +127,264 @@ type Lexer struct {
 	used       int
 	dfa        Dfa
 	state      int
-	prevState  Optional[State]
 	ignoreWord bool
 	wordLength int
 	nextTokens []Token
+	// How many of nextTokens were returned already, the slice is reused once
+	// all of them were.
+	taken int
+	// Whether only indentation was processed since the last newline.
+	atLineStart bool
+	doc         docState
+	markup      markupState
+	// Which classes of spans are skipped and whether any of them are.
+	skip     CfgSkip
+	classify bool
+	// Whether a span may begin at the next character in a comment.
+	spanStart bool
+	embedded  embeddedState
+	// Whether literals are separate tokens with the Literal flag.
+	markLiterals bool
+	// The source from the beginning of the current line.
+	line []rune
 }
 
 func buildDfa(style CommentStyle) Dfa {
 	dfa := NewDfa()
-	inCodeState := dfa.AddState(lexStateInCode)
+	codeInfo, commentInfo, stringInfo, skipInfo :=
+		lexStateInCode, lexStateInComment, lexStateInString, lexStateInSkip
+	if style.Prose {
+		// In prose the text outside of comments, strings, and skip regions
+		// is checked, so code and comments swap their roles.
+		codeInfo = lexStateInComment
+		commentInfo, stringInfo, skipInfo = lexStateInCode, lexStateInCode, lexStateInCode
+	}
+	inCodeState := dfa.AddState(codeInfo)
 	inCodeState.AddTransition("\n", inCodeState.Id())
-	inEscapeState := dfa.AddState(lexStateInEscape)
-	inCodeState.AddTransition("\x1b", inEscapeState.Id())
-	// We only support SGR sequences so escape sequences always end with `m`.
-	inEscapeState.AddTransition("m", inCodeState.Id())
 	eofState := dfa.AddState(eofStateInfo)
 	inCodeState.AddTransition(string(eofRune), eofState.Id())
-	inEscapeState.AddTransition(string(eofRune), eofState.Id())
 	// All line comment variants can share the same state.
 	// Line comments and block comments use the same info as we only need the
 	// the name to check if we are in any comment state.
-	inLineState := dfa.AddState(lexStateInComment)
-	for _, token := range style.Line {
-		inCodeState.AddTransition(token, inLineState.Id())
+	inLineState := dfa.AddState(commentInfo)
+	for _, token := range append(util.Copy(style.Line), style.DocLine...) {
+		if text, lineStart := parseTemplateText(token); lineStart {
+			inCodeState.AddLineStartTransition(text, inLineState.Id())
+		} else {
+			inCodeState.AddTransition(token, inLineState.Id())
+		}
 		inLineState.AddTransition("\n", inCodeState.Id())
-		inLineState.AddTransition("\x1b", inEscapeState.Id())
 		inLineState.AddTransition(string(eofRune), eofState.Id())
 	}
-	for i, begin := range style.BlockBegin {
-		end := style.BlockEnd[i]
+	for i, begin := range style.DocBlockBegin {
+		// A doc block begin may overlap with its end, i.e. in the empty
+		// comment `/**/`, make that a single token so the comment is closed.
+		end := []rune(style.DocBlockEnd[i])
+		for k := 1; k < len(end); k++ {
+			if strings.HasSuffix(begin, string(end[:k])) {
+				inCodeState.AddTransition(begin+string(end[k:]), inCodeState.Id())
+			}
+		}
+	}
+	blockBegin := append(util.Copy(style.BlockBegin), style.DocBlockBegin...)
+	blockEnd := append(util.Copy(style.BlockEnd), style.DocBlockEnd...)
+	for i, begin := range blockBegin {
+		end := blockEnd[i]
 		// Each block comments variant needs its own state to ensure we enter
 		// and leave the comment with matching tokens (i.e. """ vs '''
 		// doc-strings) in Python.
-		state := dfa.AddState(lexStateInComment)
-		inCodeState.AddTransition(begin, state.Id())
-		state.AddTransition(end, inCodeState.Id())
-		state.AddTransition("\x1b", inEscapeState.Id())
+		state := dfa.AddState(commentInfo)
+		if style.BlockRegex && i < len(style.BlockBegin) {
+			inCodeState.AddRegexTransition(begin, state.Id(), TokenTemplate{end, inCodeState.Id()})
+		} else {
+			inCodeState.AddTransition(begin, state.Id())
+			state.AddTransition(end, inCodeState.Id())
+		}
 		state.AddTransition("\n", state.Id())
 		state.AddTransition(string(eofRune), eofState.Id())
 		if style.BlockNesting {
-			state.MakeRecursive(begin, end)
+			state.MakeRecursive(nestingToken(style, begin), end)
 		}
 	}
 	for _, ss := range style.Strings {
-		state := dfa.AddState(lexStateInString)
-		// Note: if escape and end overlap (i.e. " and \") the scape will match
-		// first since it was added first.
-		inCodeState.AddTransition(ss.Begin, state.Id())
-		if len(ss.Escape) > 0 {
-			state.AddTransition(ss.Escape, state.Id())
+		state := dfa.AddState(stringInfo)
+		// Note: if escape and end overlap (i.e. " and \") the escape will match
+		// since it is the longer token.
+		tokens := []TokenTemplate{{ss.End, inCodeState.Id()}}
+		for _, escape := range escapeTokens(ss.Escape) {
+			tokens = append(tokens, TokenTemplate{escape, state.Id()})
+		}
+		if ss.Regex {
+			inCodeState.AddRegexTransition(ss.Begin, state.Id(), tokens...)
+		} else {
+			inCodeState.AddTransition(ss.Begin, state.Id())
+			for _, token := range tokens {
+				state.AddTransition(token.Text, token.ToState)
+			}
 		}
-		state.AddTransition(ss.End, inCodeState.Id())
-		state.AddTransition("\x1b", inEscapeState.Id())
+		state.AddTransition("\n", state.Id())
+		state.AddTransition(string(eofRune), eofState.Id())
+	}
+	for _, region := range style.SkipRegions {
+		state := dfa.AddState(skipInfo)
+		if region.Regex {
+			inCodeState.AddRegexTransition(region.Begin, state.Id())
+			for _, end := range region.End {
+				state.AddRegexTransition(end, inCodeState.Id())
+			}
+		} else {
+			inCodeState.AddTransition(region.Begin, state.Id())
+			for _, end := range region.End {
+				state.AddTransition(end, inCodeState.Id())
+			}
+		}
+		state.AddTransition("\n", state.Id())
+		state.AddTransition(string(eofRune), eofState.Id())
+		// The first end token is the one closing nested regions.
+		if len(region.Nest) != 0 && region.Regex {
+			state.MakeRegexRecursive(region.Nest, region.End[0])
+		} else if len(region.Nest) != 0 {
+			state.MakeRecursive(region.Nest, region.End[0])
+		}
+	}
+	for _, token := range style.CutOff {
+		// Like a skip region without end, but not affected by the
+		// general.skip-regions option.
+		state := dfa.AddState(skipInfo)
+		if text, lineStart := parseTemplateText(token); lineStart {
+			inCodeState.AddLineStartTransition(text, state.Id())
+		} else {
+			inCodeState.AddTransition(token, state.Id())
+		}
+		state.AddTransition("\n", state.Id())
+		state.AddTransition(string(eofRune), eofState.Id())
+	}
+	for _, cs := range style.Chars {
+		// Character literals don't need their own state, they are just tokens
+		// in code so comment tokens inside them are not matched.
+		maxLength := cs.MaxLength
+		if maxLength <= 0 {
+			maxLength = 1
+		}
+		inCodeState.AddCharLiteral(cs.Quote, cs.Escape, maxLength)
+	}
+	for i, embedded := range style.Embedded {
+		// Once this state is entered the lexer switches to the DFA of the
+		// embedded language.
+		state := dfa.AddState(lexStateEmbedded + i)
+		inCodeState.AddRegexTransition(embedded.Begin, state.Id())
+	}
+	for _, delimiter := range style.FrontMatter {
+		// Only entered by the lexer if the file begins with the delimiter.
+		state := dfa.AddState(lexStateInCode)
+		state.AddLineStartTransition(delimiter, inCodeState.Id())
 		state.AddTransition("\n", state.Id())
 		state.AddTransition(string(eofRune), eofState.Id())
 	}
 	return dfa
 }
 
+// nestingToken returns the token that begins a nested comment inside a block
+// comment beginning with begin. For doc comments this is the longest normal
+// block comment token they begin with, so `/*` nests inside `/**`.
+func nestingToken(style CommentStyle, begin string) string {
+	nest := begin
+	if util.Contains(style.DocBlockBegin, begin) {
+		for _, token := range style.BlockBegin {
+			if strings.HasPrefix(begin, token) && (nest == begin || len(token) > len(nest)) {
+				nest = token
+			}
+		}
+	}
+	return nest
+}
+
+// escapeTokens returns the tokens for a string escape. Besides the escape
+// itself an escaped escape character (i.e. `\\`) needs to be a token as well,
+// otherwise its second half would escape the end. The escape character is
+// everything before the last character of the escape, or before the first
+// group reference for regex strings.
+func escapeTokens(escape string) []string {
+	runes := []rune(escape)
+	if len(runes) == 0 {
+		return nil
+	}
+	var prefix string
+	if ref := strings.IndexByte(escape, '$'); ref > 0 {
+		prefix = escape[:ref]
+	} else if ref < 0 && len(runes) > 1 {
+		prefix = string(runes[:len(runes)-1])
+	}
+	if len(prefix) == 0 || prefix+prefix == escape {
+		return []string{escape}
+	}
+	return []string{escape, prefix + prefix}
+}
+
 func NewLexer(source string, commentStyle CommentStyle) Lexer {
 	dfa := buildDfa(commentStyle)
 	runes := []rune(source)
 	runes = append(runes, eofRune)
-	return Lexer{
+	lexer := Lexer{
 		runes,
 		0,
 		dfa,
 		lexStateInCode,
-		None[State](),
 		false,
 		0,
 		[]Token{},
+		0,
+		true,
+		newDocState(commentStyle),
+		markupState{},
+		CfgSkip{},
+		false,
+		false,
+		newEmbeddedState(commentStyle),
+		false,
+		runes,
 	}
+	lexer.enterFrontMatter(commentStyle)
+	lexer.enterState()
+	return lexer
+}
+
+// enterFrontMatter puts the lexer into the front matter state if the source
+// begins with a front matter delimiter line, the delimiter is used as code.
+func (self *Lexer) enterFrontMatter(style CommentStyle) {
+	// The front matter states are the last states of the DFA.
+	firstState := len(self.dfa.states) - len(style.FrontMatter)
+	for i, delimiter := range style.FrontMatter {
+		begin := []rune(delimiter + "\n")
+		if hasPrefix(self.source, begin) {
+			self.dfa.current = State(firstState + i)
+			self.used = len(begin) - 1
+			self.atLineStart = false
+			return
+		}
+	}
+}
+
+// SetSkip sets which classes of spans, like URLs or hashes, are skipped
+// inside comments.
+func (self *Lexer) SetSkip(skip CfgSkip) {
+	self.skip = skip
+	self.classify = anySpanClass(&skip)
+}
+
+// SetMarkLiterals makes string and character literals separate Code tokens
+// with the Literal flag. Strings spanning multiple lines have a token for each
+// line.
+func (self *Lexer) SetMarkLiterals(mark bool) {
+	self.markLiterals = mark
+}
+
+// codeKind returns the kind of the code tokens ending the given state.
+func (self *Lexer) codeKind(state int) TokenKindType {
+	if self.markLiterals && state == lexStateInString {
+		return TokenKind.Code | TokenKind.Literal
+	}
+	return TokenKind.Code
 }
 
 // drop drops count characters from the source.
@@ -211,6 +424,25 @@ func (self *Lexer) createMarker(kind TokenKindType) Token {
 	}
 }
 
+// dictionaryPragma begins a comment word that selects the dictionaries for the
+// rest of the file, it's followed by a comma separated list of names.
+var dictionaryPragma = []rune("spellcheck:lang=")
+
+// pragmaLength returns the length of the pragma at the beginning of the source,
+// it ends at whitespace or a token.
+func (self *Lexer) pragmaLength() int {
+	length := len(dictionaryPragma)
+	for length < len(self.source) {
+		c := self.source[length]
+		if unicode.IsSpace(c) || c == eofRune || c == '\x1b' ||
+			self.dfa.Peek(self.source[length:], false) != 0 {
+			break
+		}
+		length++
+	}
+	return length
+}
+
 func isWordChar(char rune) bool {
 	return unicode.IsLetter(char) || char == '-' || char == '\'' || char == '_'
 }
@@ -218,32 +450,125 @@ func isWordChar(char rune) bool {
 // processInComment processes one character inside a comment, adding tokens
 // to the internal list.
 func (self *Lexer) processInComment(char rune) {
-	addToken := func(t Token) {
-		self.nextTokens = append(self.nextTokens, t)
-	}
 	inWord := self.wordLength > 1
-	if (char == '@' || char == '\\') && !inWord {
+	spanStart := self.spanStart
+	self.spanStart = unicode.IsSpace(char) || isSpanOpening(char)
+	if spanStart && hasPrefix(self.source[self.used-1:], dictionaryPragma) {
+		self.used--
+		self.finishWord()
+		addToken := func(t Token) {
+			self.nextTokens = append(self.nextTokens, t)
+		}
 		self.createToken(TokenKind.Code).Then(addToken)
+		self.used = self.pragmaLength()
+		self.createToken(TokenKind.Pragma).Then(addToken)
+		self.spanStart = false
+	} else if skip := self.markup.process(self.source[self.used-1:]); skip != 0 {
+		// Markup ends the current word, the skipped text is just code.
+		self.used--
+		self.finishWord()
+		self.used += skip
+	} else if skip := self.spanLength(spanStart); skip != 0 {
+		self.used--
+		self.finishWord()
+		self.used += skip
+		self.spanStart = false
+	} else if (char == '@' || char == '\\') && !inWord {
+		self.createToken(TokenKind.Code).Then(func(t Token) {
+			self.nextTokens = append(self.nextTokens, t)
+		})
 		self.ignoreWord = true
-	} else if isWordChar(char) {
+	} else if isWordChar(char) && (self.wordLength > 0 || unicode.IsLetter(char)) {
+		// Words need to start with a letter so punctuation like the `--` in
+		// `-->` is not taken as a word.
 		self.wordLength++
-	} else if inWord {
+	} else {
+		// The word ends before the current character.
+		self.used--
+		self.finishWord()
+		self.used++
+	}
+}
+
+// spanLength returns the length of the span beginning at the last used
+// character if it belongs to a skipped class, otherwise 0.
+func (self *Lexer) spanLength(spanStart bool) int {
+	if !spanStart || !self.classify {
+		return 0
+	}
+	begin := self.used - 1
+	end := begin
+	letters := true
+	for end < len(self.source) {
+		c := self.source[end]
+		if unicode.IsSpace(c) || c == eofRune || c == '\x1b' {
+			break
+		}
+		letters = letters && unicode.IsLetter(c)
+		end++
+	}
+	// Plain words never belong to any class.
+	if letters {
+		return 0
+	}
+	span := self.source[begin:end]
+	span = span[:trimSpan(span)]
+	if !classifySpan(string(span), &self.skip) {
+		return 0
+	}
+	// The span must not contain any tokens, like the end of a block comment.
+	for i := 1; i < len(span); i++ {
+		if self.dfa.Peek(self.source[begin+i:], false) != 0 {
+			span = span[:trimSpan(span[:i])]
+			if !classifySpan(string(span), &self.skip) {
+				return 0
+			}
+			break
+		}
+	}
+	return len(span)
+}
+
+// finishWord ends the current word, which ends at the last used character.
+func (self *Lexer) finishWord() {
+	addToken := func(t Token) {
+		self.nextTokens = append(self.nextTokens, t)
+	}
+	if self.wordLength > 1 {
 		if self.ignoreWord {
 			self.ignoreWord = false
 		} else {
-			self.used -= 1
 			self.used -= self.wordLength
 			self.createToken(TokenKind.Code).Then(addToken)
 			self.used = self.wordLength
 			self.createToken(TokenKind.CommentWord).Then(addToken)
-			self.used++
 		}
-		self.wordLength = 0
+	}
+	self.wordLength = 0
+}
+
+// processEscape handles the escape sequence starting at the last used
+// character. SGR sequences are turned into Style tokens, any other sequences
+// are dropped since they have no meaning to us.
+func (self *Lexer) processEscape() {
+	self.used--
+	self.createToken(TokenKind.Code).Then(func(t Token) {
+		self.nextTokens = append(self.nextTokens, t)
+	})
+	length, kind := util.ScanEscape(self.source)
+	if kind == util.EscapeSGR {
+		self.used = length
+		self.nextTokens = append(self.nextTokens, self.createToken(TokenKind.Style).Unwrap())
 	} else {
-		self.wordLength = 0
+		self.drop(length)
 	}
 }
 
+// column returns the column of the next character.
+func (self *Lexer) column() int {
+	return len(self.line) - len(self.source[self.used:])
+}
+
 // getNextTokens processes the source until at least 1 new token is created.
 func (self *Lexer) getNextTokens() {
 	// Note: regarding the doc comment, we do not stop once we have a token
@@ -257,14 +582,58 @@ func (self *Lexer) getNextTokens() {
 		return
 	}
 	for {
-		char := self.source[self.used]
-		self.used++
+		if self.embedded.atEnd(self.source[self.used:], self.atLineStart) {
+			self.leaveEmbedded()
+			return
+		}
+		stateChanged, tokenLength := self.dfa.Process(self.source[self.used:], self.atLineStart)
+		if tokenLength == 0 {
+			char := self.source[self.used]
+			self.used++
+			if char == '\n' {
+				self.atLineStart = true
+				self.line = self.source[self.used:]
+			} else if char != ' ' && char != '\t' && char != '\x1b' {
+				self.atLineStart = false
+			}
+			if self.state == lexStateInComment {
+				self.processInComment(char)
+			} else if self.state == lexStateInCode && char != '\x1b' {
+				self.doc.trackCode(char)
+				if !unicode.IsSpace(char) {
+					self.markup.code()
+				}
+			}
+			if char == '\x1b' {
+				self.processEscape()
+				return
+			}
+			continue
+		}
+		// A token begins at the current character so it also ends any word.
 		if self.state == lexStateInComment {
-			self.processInComment(char)
+			self.finishWord()
+		}
+		lineStart := self.atLineStart
+		wasCode := self.state == lexStateInCode
+		self.used += tokenLength
+		token := self.source[self.used-tokenLength : self.used]
+		char := self.source[self.used-1]
+		self.atLineStart = char == '\n'
+		if self.atLineStart {
+			self.line = self.source[self.used:]
 		}
-		stateChanged, tokenLength := self.dfa.Process(char)
 		if stateChanged {
 			self.state = self.dfa.CurrentState().info
+			if self.state >= lexStateEmbedded {
+				self.enterEmbedded(self.state-lexStateEmbedded, token, lastState.info == lexStateInComment)
+				return
+			}
+			if wasCode && self.state != lexStateInComment {
+				for _, c := range token {
+					self.doc.trackCode(c)
+				}
+			}
 			if self.state == eofStateInfo {
 				self.used--
 				self.createToken(TokenKind.Code).Then(addToken)
@@ -278,12 +647,20 @@ func (self *Lexer) getNextTokens() {
 			case lexTransition{lexStateInCode, lexStateInComment}:
 				self.used -= tokenLength
 				self.createToken(TokenKind.Code).Then(addToken)
-				addToken(self.createMarker(TokenKind.CommentBegin))
+				if token[0] == '\n' {
+					// The end of a line comment in prose.
+					self.drop(1)
+					addToken(self.createMarker(TokenKind.Newline))
+					tokenLength--
+				}
+				kind := TokenKind.CommentBegin
+				if self.doc.isDocComment(string(token), self.source[self.used+tokenLength:], lineStart) {
+					kind |= TokenKind.Doc
+				}
+				addToken(self.createMarker(kind))
 				self.used += tokenLength
-
-			case lexTransition{lexStateInCode, lexStateInEscape}:
-				self.used -= tokenLength
-				self.createToken(TokenKind.Code).Then(addToken)
+				self.markup.beginLine(self.column())
+				self.spanStart = true
 
 			case lexTransition{lexStateInComment, lexStateInCode}:
 				if char == '\n' {
@@ -291,41 +668,58 @@ func (self *Lexer) getNextTokens() {
 				}
 				self.createToken(TokenKind.Code).Then(addToken)
 				addToken(self.createMarker(TokenKind.CommentEnd))
+				self.markup.endComment()
 				if char == '\n' {
 					self.drop(1)
 					addToken(self.createMarker(TokenKind.Newline))
 				}
 
-			case lexTransition{lexStateInEscape, lexStateInCode}:
-				addToken(self.createToken(TokenKind.Style).Unwrap())
-				self.prevState.Take().Then(func(id State) {
-					// When finishing a escape sequence we always go back to
-					// the code state so if there was a escape sequence in a
-					// different state we need to manually go back to that
-					// state.
-					self.dfa.ForceState(id)
-					self.state = self.dfa.CurrentState().info
-				})
-
-			// Note: due to the above the `escape -> comment` transition does not exist.
+			case lexTransition{lexStateInCode, lexStateInString}:
+				if self.markLiterals {
+					// The literal token includes the delimiter.
+					self.used -= tokenLength
+					self.createToken(TokenKind.Code).Then(addToken)
+					self.used += tokenLength
+				}
 
-			case lexTransition{lexStateInComment, lexStateInEscape}:
+			case lexTransition{lexStateInString, lexStateInCode}:
+				if self.markLiterals && char != '\n' {
+					self.createToken(self.codeKind(lastState.info)).Then(addToken)
+					break
+				}
+				// Strings may end at the end of the line (i.e. multiline
+				// string literals in Zig).
+				fallthrough
+			case lexTransition{lexStateInSkip, lexStateInCode}:
+				fallthrough
+			case lexTransition{lexStateInSkip, lexStateInSkip}:
 				fallthrough
-			case lexTransition{lexStateInString, lexStateInEscape}:
-				self.used -= tokenLength
-				self.prevState = Some(lastState.id)
-				self.createToken(TokenKind.Code).Then(addToken)
-
 			case lexTransition{lexStateInCode, lexStateInCode}:
+				if self.markLiterals && char != '\n' {
+					// A character literal.
+					self.used -= tokenLength
+					self.createToken(TokenKind.Code).Then(addToken)
+					self.used += tokenLength
+					self.createToken(TokenKind.Code | TokenKind.Literal).Then(addToken)
+					break
+				}
 				fallthrough
 			case lexTransition{lexStateInString, lexStateInString}:
 				fallthrough
 			case lexTransition{lexStateInComment, lexStateInComment}:
-				// Caused by a newline.
+				// Caused by a newline or a string escape (i.e. `\"`), only the
+				// former needs to be handled.
+				if char != '\n' {
+					break
+				}
 				self.used -= 1
-				self.createToken(TokenKind.Code).Then(addToken)
+				self.createToken(self.codeKind(lastState.info)).Then(addToken)
 				self.drop(1)
 				addToken(self.createMarker(TokenKind.Newline))
+				if self.state == lexStateInComment {
+					self.markup.beginLine(0)
+					self.spanStart = true
+				}
 			}
 			break
 		}
@@ -334,9 +728,11 @@ func (self *Lexer) getNextTokens() {
 
 // Next returns the next token. If the input is exhausted all calls return EOF.
 func (self *Lexer) Next() (t Token) {
-	for len(self.nextTokens) == 0 {
+	for self.taken == len(self.nextTokens) {
+		self.nextTokens, self.taken = self.nextTokens[:0], 0
 		self.getNextTokens()
 	}
-	t, self.nextTokens = util.PopFront(self.nextTokens)
+	t = self.nextTokens[self.taken]
+	self.taken++
 	return t
 }
@@ -209,3 +209,523 @@ func TestNesting(t *testing.T) {
 		style,
 	)
 }
+
+func TestTrueColorStyle(t *testing.T) {
+	Expect(
+		t,
+		"//\x1b[38;2;255;0;0mred\x1b[38:2::0:255:0m green",
+		[]Token{
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.Style, "\x1b[38;2;255;0;0m"),
+			newToken(TokenKind.CommentWord, "red"),
+			newToken(TokenKind.Style, "\x1b[38:2::0:255:0m"),
+			newToken(TokenKind.Code, " "),
+			newToken(TokenKind.CommentWord, "green"),
+			newToken(TokenKind.EOF),
+		},
+	)
+}
+
+func TestDropNonSgrSequences(t *testing.T) {
+	Expect(
+		t,
+		"// \x1b]8;;https://example.com\x1b\\link\x1b]8;;\x07 \x1b[2Kword\x1b(B",
+		[]Token{
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "// "),
+			newToken(TokenKind.CommentWord, "link"),
+			newToken(TokenKind.Code, " "),
+			newToken(TokenKind.CommentWord, "word"),
+			newToken(TokenKind.EOF),
+		},
+	)
+}
+
+func TestIncompleteEscape(t *testing.T) {
+	Expect(
+		t,
+		"a\x1b[38;5",
+		[]Token{
+			newToken(TokenKind.Code, "a"),
+			newToken(TokenKind.EOF),
+		},
+	)
+}
+
+func TestEscapedEscape(t *testing.T) {
+	Expect(
+		t,
+		"\"\\\\\"//aa",
+		[]Token{
+			newToken(TokenKind.Code, "\"\\\\\""),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.EOF),
+		},
+	)
+}
+
+func TestLongTokens(t *testing.T) {
+	style := CommentStyle{
+		Line:       []string{"#"},
+		BlockBegin: []string{"<!--[if", "«««"},
+		BlockEnd:   []string{"<![endif]-->", "»»»"},
+	}
+	Expect(
+		t,
+		"<!--[if aa <![endif]--> # bb\n««« cc »»»",
+		[]Token{
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "<!--[if "),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.Code, " <![endif]-->"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Code, " "),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "# "),
+			newToken(TokenKind.CommentWord, "bb"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "««« "),
+			newToken(TokenKind.CommentWord, "cc"),
+			newToken(TokenKind.Code, " »»»"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func TestWordBeforeEndToken(t *testing.T) {
+	style := CommentStyle{
+		BlockBegin: []string{"<!--"},
+		BlockEnd:   []string{"-->"},
+	}
+	Expect(
+		t,
+		"<!--aa-->",
+		[]Token{
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "<!--"),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.Code, "-->"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func BenchmarkLexer(b *testing.B) {
+	source := strings.Repeat(
+		"int main() { // the main function\n\tputs(\"hello // world\"); /* a block\ncomment */\n}\n",
+		1000,
+	)
+	for i := 0; i < b.N; i++ {
+		lexer := NewLexer(source, cCommentStyle)
+		for {
+			if token := lexer.Next(); token.kind == TokenKind.EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestRegexDelimiters(t *testing.T) {
+	style := CommentStyle{
+		BlockBegin: []string{`\{(-+)`},
+		BlockEnd:   []string{"${1}}"},
+		BlockRegex: true,
+		Strings: []StringStyle{
+			{Begin: `q(.)`, End: "${1}", Escape: `\${1}`, Regex: true},
+		},
+	}
+	Expect(
+		t,
+		"q|{-\\|\\\\|{-- aa -} bb --}",
+		[]Token{
+			newToken(TokenKind.Code, "q|{-\\|\\\\|"),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "{-- "),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.Code, " -} "),
+			newToken(TokenKind.CommentWord, "bb"),
+			newToken(TokenKind.Code, " --}"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func TestLineStartTemplate(t *testing.T) {
+	style := CommentStyle{
+		Line: []string{"#"},
+		Strings: []StringStyle{
+			{Begin: `<<(\w+)`, End: "^${1}", Regex: true},
+		},
+	}
+	Expect(
+		t,
+		"<<X #X\n  X#aa",
+		[]Token{
+			newToken(TokenKind.Code, "<<X #X"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "  X"),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "#"),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func TestCharLiteralLength(t *testing.T) {
+	literal := charLiteral{[]rune("'"), []rune("\\"), 1}
+	tests := []struct {
+		input  string
+		length int
+	}{
+		{"'a' b", 3},
+		{"'\\'' b", 4},
+		{"'\\u{1F600}'", 11},
+		{"'\\u{1F600}xyz'", 0},
+		{"'ab'", 0},
+		{"''", 0},
+		{"'a", 0},
+		{"'\n'", 0},
+		{"'static str", 0},
+	}
+	for _, test := range tests {
+		if length := literal.length([]rune(test.input)); length != test.length {
+			t.Errorf("%q: got %d, expected %d", test.input, length, test.length)
+		}
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	style := CommentStyle{
+		Line: []string{"//"},
+		Strings: []StringStyle{
+			{Begin: "\"", End: "\""},
+		},
+		Chars: []CharStyle{
+			{Quote: "'", Escape: "\\", MaxLength: 2},
+		},
+	}
+	Expect(
+		t,
+		"'\"' '\\'' '//' 'a \"//\" //aa",
+		[]Token{
+			newToken(TokenKind.Code, "'\"' '\\'' '//' 'a \"//\" "),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func TestSkipRegions(t *testing.T) {
+	style := cCommentStyle
+	style.SkipRegions = []SkipRegion{
+		{Begin: "#if 0", End: []string{"#endif", "#else"}, Nest: "#if"},
+	}
+	Expect(
+		t,
+		"#if 0\n#if X //aa\n#else\n#endif\n#else //bb",
+		[]Token{
+			newToken(TokenKind.Code, "#if 0"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "#if X //aa"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "#else"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "#endif"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "#else "),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "bb"),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func TestRegexSkipRegions(t *testing.T) {
+	style := cCommentStyle
+	style.SkipRegions = []SkipRegion{
+		{
+			Begin: `^#[ \t]*if[ \t]+0[ \t]*(?:(?m:$)|//)`,
+			End:   []string{`^#[ \t]*endif\b`, `^#[ \t]*else\b`},
+			Nest:  `^#[ \t]*if`,
+			Regex: true,
+		},
+	}
+	Expect(
+		t,
+		"#if 0x1 //aa\n # if 0\n// #if\n#  endif //bb",
+		[]Token{
+			newToken(TokenKind.Code, "#if 0x1 "),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, " # if 0"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "// #if"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "#  endif "),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "bb"),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func TestMarkLiterals(t *testing.T) {
+	style := cCommentStyle
+	style.Strings = style.Strings[:1]
+	style.Chars = []CharStyle{{Quote: "'", Escape: "\\"}}
+	lexer := NewLexer("f(\"a\\\"b\nc\", 'd') //ee", style)
+	lexer.SetMarkLiterals(true)
+	ExpectOutput(
+		lexer,
+		[]Token{
+			newToken(TokenKind.Code, "f("),
+			newToken(TokenKind.Code|TokenKind.Literal, "\"a\\\"b"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code|TokenKind.Literal, "c\""),
+			newToken(TokenKind.Code, ", "),
+			newToken(TokenKind.Code|TokenKind.Literal, "'d'"),
+			newToken(TokenKind.Code, ") "),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "ee"),
+			newToken(TokenKind.EOF),
+		},
+		tokenInfoEq,
+		t,
+	)
+}
+
+func TestProse(t *testing.T) {
+	style := CommentStyle{
+		Line:        []string{"//"},
+		BlockBegin:  []string{"<!--"},
+		BlockEnd:    []string{"-->"},
+		Prose:       true,
+		FrontMatter: []string{"---"},
+	}
+	Expect(
+		t,
+		"---\nx\n---\naa // no\nbb <!--cc-->",
+		[]Token{
+			newToken(TokenKind.Code, "---"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "x"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "---"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.Code, " //"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Code, " no"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.CommentWord, "bb"),
+			newToken(TokenKind.Code, " <!--"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Code, "cc"),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "-->"),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+	Expect(
+		t,
+		"aa",
+		[]Token{
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+func TestEmbedded(t *testing.T) {
+	style := CommentStyle{
+		BlockBegin: []string{"<!--"},
+		BlockEnd:   []string{"-->"},
+		Embedded: []EmbeddedLanguage{
+			{Begin: `<(s|p)>`, End: "</${1}>", Language: "${1}"},
+		},
+	}
+	lexer := NewLexer("a<s>//bb</s>\n<s>/*cc\n</s>dd<p>ee</p><!--ff-->", style)
+	lexer.SetLanguages(func(language string) CommentStyle {
+		if language == "p" {
+			return CommentStyle{Prose: true}
+		}
+		return cCommentStyle
+	})
+	ExpectOutput(
+		lexer,
+		[]Token{
+			newToken(TokenKind.Code, "a<s>"),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "bb"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Code, "</s>"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "<s>"),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "/*"),
+			newToken(TokenKind.CommentWord, "cc"),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Code, "</s>"),
+			newToken(TokenKind.Code, "dd<p>"),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.CommentWord, "ee"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Code, "</p>"),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "<!--"),
+			newToken(TokenKind.CommentWord, "ff"),
+			newToken(TokenKind.Code, "-->"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.EOF),
+		},
+		tokenInfoEq,
+		t,
+	)
+}
+
+func TestDocComments(t *testing.T) {
+	style := cCommentStyle
+	style.DocLine = []string{"///"}
+	style.DocBlockBegin = []string{"/**"}
+	style.DocBlockEnd = []string{"*/"}
+	Expect(
+		t,
+		"///aa\n//bb\n/**/",
+		[]Token{
+			newToken(TokenKind.CommentBegin | TokenKind.Doc),
+			newToken(TokenKind.Code, "///"),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "//"),
+			newToken(TokenKind.CommentWord, "bb"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.Newline),
+			newToken(TokenKind.Code, "/**/"),
+			newToken(TokenKind.EOF),
+		},
+		style,
+	)
+}
+
+// words returns the text of all comment words in source.
+func words(source string, style CommentStyle) []string {
+	lexer := NewLexer(source, style)
+	result := []string{}
+	for {
+		token := lexer.Next()
+		switch token.kind {
+		case TokenKind.CommentWord:
+			result = append(result, token.text)
+		case TokenKind.EOF:
+			return result
+		}
+	}
+}
+
+func TestMarkup(t *testing.T) {
+	tests := []struct {
+		source string
+		words  []string
+	}{
+		{"// aa `bb` cc ``dd ` ee`` ff", []string{"aa", "cc", "ff"}},
+		{"// aa `bb\n// cc", []string{"aa", "cc"}},
+		{"// aa\n// ```go\n// bb\n//\n// ```\n// cc", []string{"aa", "cc"}},
+		{"/* aa\n * ~~~~\n * bb\n * ~~~~\n */ // cc", []string{"aa", "cc"}},
+		{"// ```\n// aa\nx()\n// bb", []string{"bb"}},
+		{"// see [aa bb](https://cc.dd/ee) ff", []string{"see", "aa", "bb", "ff"}},
+		{"/** aa {@link bb#cc dd} ee */", []string{"aa", "ee"}},
+		{"// use :func:`aa` and :py:class:`bb` cc: dd", []string{"use", "and", "cc", "dd"}},
+		{"/* aa::\n *\n *     bb\n * cc\n */", []string{"aa", "cc"}},
+		{"// .. note:: aa\n//    bb\n// cc\nx() // .. dd\n// ee", []string{"cc", "ee"}},
+	}
+	for _, test := range tests {
+		got := words(test.source, cCommentStyle)
+		if strings.Join(got, " ") != strings.Join(test.words, " ") {
+			t.Errorf("%q: got %q, expected %q", test.source, got, test.words)
+		}
+	}
+}
+
+func TestSkipSpans(t *testing.T) {
+	all := CfgSkip{Urls: true, Emails: true, Paths: true, Hex: true, Uuids: true, Base64: true}
+	tests := []struct {
+		source string
+		skip   CfgSkip
+		words  []string
+	}{
+		{"// see https://example.com/some/path.", all, []string{"see"}},
+		{"// (see <http://exmple.org/a_b?c=d>)", all, []string{"see"}},
+		{"/* www.exmple.com*/ aa", all, []string{}},
+		{"// mail foo.bar+baz@exmple.co.uk now", all, []string{"mail", "now"}},
+		{"// in /usr/lib/foo, ~/bar, ./baz and src/foo/bar.go", all, []string{"in", "and"}},
+		{"// and/or either/or", all, []string{"and", "or", "either", "or"}},
+		{"// fixed in 3fa85f6 and deadbeef 0xCAFE", all, []string{"fixed", "in", "and", "deadbeef"}},
+		{"// id 3fa85f64-5717-4562-b3fc-2c963f66afa6", all, []string{"id"}},
+		{"// key c2VjcmV0IGtleSB2YWx1ZQ== internationalization", all, []string{"key", "internationalization"}},
+		{"// see https://exmple.com", CfgSkip{Emails: true}, []string{"see", "https", "exmple", "com"}},
+		{"// sha 3fa85f6", CfgSkip{}, []string{"sha", "fa"}},
+	}
+	for _, test := range tests {
+		lexer := NewLexer(test.source, cCommentStyle)
+		lexer.SetSkip(test.skip)
+		got := []string{}
+		for token := lexer.Next(); token.kind != TokenKind.EOF; token = lexer.Next() {
+			if token.kind == TokenKind.CommentWord {
+				got = append(got, token.text)
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(test.words, " ") {
+			t.Errorf("%q: got %q, expected %q", test.source, got, test.words)
+		}
+	}
+}
+
+func TestDictionaryPragma(t *testing.T) {
+	Expect(
+		t,
+		"/* aa spellcheck:lang=de,default*/",
+		[]Token{
+			newToken(TokenKind.CommentBegin),
+			newToken(TokenKind.Code, "/* "),
+			newToken(TokenKind.CommentWord, "aa"),
+			newToken(TokenKind.Code, " "),
+			newToken(TokenKind.Pragma, "spellcheck:lang=de,default"),
+			newToken(TokenKind.Code, "*/"),
+			newToken(TokenKind.CommentEnd),
+			newToken(TokenKind.EOF),
+		},
+	)
+}
@@ -0,0 +1,200 @@
+package parser
+
+import "unicode"
+
+// markupState tracks Markdown, reStructuredText, and Javadoc constructs in
+// comments whose content is not prose.
+type markupState struct {
+	// The sequence that ends the current inline span, nil if not in one.
+	spanEnd []rune
+	// The fence that began the current fenced code block, nil if not in one.
+	// This persists across comments so fences work with line comments.
+	fence []rune
+	// Whether only whitespace and decoration was seen on the current line.
+	lineBegin bool
+	// Whether the rest of the line is skipped.
+	skipLine bool
+	// The indentation of the current line, including decoration and the
+	// text before the comment.
+	indent int
+	// Whether we are in a reStructuredText block that is skipped, an explicit
+	// markup block (i.e. `.. note::`) or a literal block after `::`. The block
+	// continues while lines are indented further than blockIndent.
+	inBlock     bool
+	blockIndent int
+	// Whether the last line ended with `::`, so an indented block follows.
+	literalNext bool
+}
+
+// runLength returns how often the first character of input is repeated at its
+// beginning.
+func runLength(input []rune) int {
+	n := 1
+	for n < len(input) && input[n] == input[0] {
+		n++
+	}
+	return n
+}
+
+// roleLength returns the length of a reStructuredText role (i.e. `:func:` or
+// `:py:class:`) at the beginning of input, the role must be followed by a
+// backtick. Returns 0 if there is no role.
+func roleLength(input []rune) int {
+	for i := 1; i < len(input); i++ {
+		c := input[i]
+		if c == ':' {
+			if i > 1 && i+1 < len(input) {
+				if input[i+1] == '`' {
+					return i + 1
+				}
+				if rest := roleLength(input[i:]); rest != 0 {
+					return i + rest
+				}
+			}
+			return 0
+		}
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '_' && c != '.' {
+			return 0
+		}
+	}
+	return 0
+}
+
+// isExplicitMarkup returns true if input begins with the `..` of a
+// reStructuredText explicit markup block, like a directive or comment.
+func isExplicitMarkup(input []rune) bool {
+	return hasPrefix(input, []rune("..")) &&
+		(len(input) == 2 || unicode.IsSpace(input[2]) || input[2] == eofRune)
+}
+
+// endsWithLiteral returns true if input begins with the `::` that ends a
+// line and introduces a reStructuredText literal block.
+func endsWithLiteral(input []rune) bool {
+	if !hasPrefix(input, []rune("::")) {
+		return false
+	}
+	for _, c := range input[2:] {
+		if c == '\n' || c == eofRune {
+			return true
+		} else if c != ' ' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// beginContent is called for the first character on a line that is not
+// indentation, it updates the skipped blocks and returns whether the line is
+// part of one.
+func (self *markupState) beginContent(input []rune) bool {
+	if self.literalNext {
+		self.literalNext = false
+		if self.indent > self.blockIndent {
+			self.inBlock = true
+		}
+	}
+	if self.inBlock && self.indent > self.blockIndent {
+		return true
+	}
+	self.inBlock = false
+	if isExplicitMarkup(input) {
+		self.inBlock = true
+		self.blockIndent = self.indent
+		return true
+	}
+	return false
+}
+
+// process checks for markup at the beginning of input, which begins with the
+// current character. Returns the number of characters that are not prose and
+// should be skipped, 0 if the current character should be processed normally.
+func (self *markupState) process(input []rune) int {
+	char := input[0]
+	lineBegin := self.lineBegin
+	if char != ' ' && char != '\t' && char != '*' && char != '\x1b' {
+		self.lineBegin = false
+	} else if lineBegin {
+		self.indent++
+	}
+	if self.skipLine {
+		return 1
+	}
+	if lineBegin && !self.lineBegin && self.fence == nil && self.beginContent(input) {
+		self.skipLine = true
+		return 1
+	}
+	if lineBegin && (char == '`' || char == '~') {
+		if run := runLength(input); run >= 3 {
+			if self.fence == nil {
+				self.fence = input[:run:run]
+				self.skipLine = true
+				return run
+			} else if self.fence[0] == char && run >= len(self.fence) {
+				self.fence = nil
+				self.skipLine = true
+				return run
+			}
+		}
+	}
+	if self.fence != nil {
+		return 1
+	}
+	if self.spanEnd != nil {
+		if hasPrefix(input, self.spanEnd) {
+			length := len(self.spanEnd)
+			self.spanEnd = nil
+			return length
+		}
+		return 1
+	}
+	next := rune(0)
+	if len(input) > 1 {
+		next = input[1]
+	}
+	switch {
+	case char == '`':
+		// Inline code, this also covers the double backtick literals of
+		// reStructuredText.
+		run := runLength(input)
+		self.spanEnd = input[:run:run]
+		return run
+	case char == ']' && next == '(':
+		// Link target
+		self.spanEnd = []rune{')'}
+		return 2
+	case char == '{' && next == '@':
+		// Javadoc inline tag
+		self.spanEnd = []rune{'}'}
+		return 2
+	case char == ':':
+		if endsWithLiteral(input) {
+			self.literalNext = true
+			self.blockIndent = self.indent
+		}
+		return roleLength(input)
+	}
+	return 0
+}
+
+// beginLine is called at the beginning of a line in a comment, which begins
+// in the given column.
+func (self *markupState) beginLine(column int) {
+	self.spanEnd = nil
+	self.lineBegin = true
+	self.skipLine = false
+	self.indent = column
+}
+
+// endComment is called at the end of a comment.
+func (self *markupState) endComment() {
+	self.spanEnd = nil
+	self.skipLine = false
+}
+
+// code is called for code outside of comments that is not whitespace, this
+// ends fenced code blocks and indented blocks that were not closed.
+func (self *markupState) code() {
+	self.fence = nil
+	self.inBlock = false
+	self.literalNext = false
+}
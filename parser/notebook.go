@@ -0,0 +1,72 @@
+package parser
+
+import (
+	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/notebook"
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+)
+
+// tokenList iterates over a list of tokens, returning EOF at the end.
+type tokenList struct {
+	tokens []Token
+}
+
+func (self *tokenList) Next() Token {
+	if len(self.tokens) == 0 {
+		return Token{TokenKind.EOF, ""}
+	}
+	t := self.tokens[0]
+	self.tokens = self.tokens[1:]
+	return t
+}
+
+// notebookTokens returns the tokens for the text of the notebook, each cell is
+// lexed with the style for its type.
+func notebookTokens(nb *notebook.Notebook, proseStyle, codeStyle CommentStyle, cfg *Config) []Token {
+	tokens := []Token{}
+	for _, cell := range nb.Cells {
+		tokens = append(tokens, Token{TokenKind.Code, cell.Header()}, Token{TokenKind.Newline, ""})
+		// Raw cells are not checked.
+		style := CommentStyle{}
+		switch cell.Kind {
+		case "markdown":
+			style = proseStyle
+		case "code":
+			style = codeStyle
+		}
+		lexer := configuredLexer(cell.Source, style, cfg)
+		inComment := false
+		for t := lexer.Next(); t.Kind() != TokenKind.EOF; t = lexer.Next() {
+			switch t.Kind() {
+			case TokenKind.CommentBegin:
+				inComment = true
+			case TokenKind.CommentEnd:
+				inComment = false
+			}
+			tokens = append(tokens, t)
+		}
+		// A comment at the end of the cell does not continue in the next one.
+		if inComment {
+			tokens = append(tokens, Token{TokenKind.CommentEnd, ""})
+		}
+		tokens = append(tokens, Token{TokenKind.Newline, ""})
+	}
+	return tokens
+}
+
+// ParseNotebook parses a Jupyter notebook. Markdown cells are checked with the
+// prose style and code cells with the style of the notebook language.
+func ParseNotebook(
+	fileName string,
+	nb *notebook.Notebook,
+	proseStyle, codeStyle CommentStyle,
+	speller Dictionaries,
+	cfg *Config,
+	ignoreList *IgnoreList,
+	counts *WordCounts,
+) sf.SourceFile {
+	tokens := tokenList{notebookTokens(nb, proseStyle, codeStyle, cfg)}
+	source := parseTokens(fileName, &tokens, codeStyle, speller, cfg, ignoreList, counts, true)
+	source.SetEncoder(nb)
+	return source
+}
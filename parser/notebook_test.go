@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/notebook"
+)
+
+func TestNotebookTokens(t *testing.T) {
+	nb, err := notebook.Parse([]byte(`{"cells": [
+		{"cell_type": "markdown", "source": ["one\n", "two <!-- no -->"]},
+		{"cell_type": "code", "source": "x = 1 // three\n"},
+		{"cell_type": "raw", "source": "no"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prose := CommentStyle{BlockBegin: []string{"<!--"}, BlockEnd: []string{"-->"}, Prose: true}
+	cfg := DefaultConfig()
+	var text strings.Builder
+	words := []string{}
+	depth := 0
+	for _, token := range notebookTokens(nb, prose, cCommentStyle, &cfg) {
+		switch token.Kind() {
+		case TokenKind.Newline:
+			text.WriteByte('\n')
+		case TokenKind.CommentWord:
+			words = append(words, token.text)
+		case TokenKind.CommentBegin:
+			depth++
+		case TokenKind.CommentEnd:
+			depth--
+		}
+		text.WriteString(token.text)
+		if depth < 0 || depth > 1 {
+			t.Fatalf("unbalanced comments")
+		}
+	}
+	if text.String() != nb.Text() {
+		t.Errorf("got text %q, expected %q", text.String(), nb.Text())
+	}
+	if !reflect.DeepEqual(words, []string{"one", "two", "three"}) {
+		t.Errorf("got words %q", words)
+	}
+}
+
+func TestNotebookEscapes(t *testing.T) {
+	data := `{"cells": [
+		{"cell_type": "code", "source": "f(\"\u001b[1mbold\u001b[0m\") // commnet \u001b[m\nx = \"\u001b]0;title\u0007\""}
+	]}`
+	nb, err := notebook.Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	ignoreList := NewIgnoreList(false)
+	source := ParseNotebook("", nb, CommentStyle{Prose: true}, cCommentStyle, Dictionaries{}, &cfg, &ignoreList, NewWordCounts())
+	if encoded, err := source.Bytes(); err != nil || string(encoded) != data {
+		t.Errorf("unchanged text changed the notebook: %s %v", encoded, err)
+	}
+	words := source.Words()
+	if len(words) != 1 || words[0].Original != "commnet" {
+		t.Fatalf("got words %v", words)
+	}
+	source.Text().SetSliceText(words[0].Index, "comment")
+	encoded, err := source.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.Replace(data, "commnet", "comment", 1); string(encoded) != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", encoded, expected)
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
-	"github.com/trustmaster/go-aspell"
 
 	. "github.com/JaMo42/spellcheck_comments/common"
 	sf "github.com/JaMo42/spellcheck_comments/source_file"
@@ -52,6 +52,12 @@ func IsWord(s string) bool {
 	return letters >= 2
 }
 
+// isLetter returns true if s is a single letter.
+func isLetter(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return size == len(s) && unicode.IsLetter(r)
+}
+
 // TrimSymbols strips the string from leading and trailing ascii punctuation
 // characters.
 func TrimSymbols(s string) (string, string, string) {
@@ -170,19 +176,152 @@ func FilterCommentedCode(
 	return words
 }
 
+// identifierPattern matches the identifiers in code.
+var identifierPattern = regexp.MustCompile(`[\p{L}_][\p{L}\p{N}_]*`)
+
+// CollectIdentifiers adds the identifiers in the code of a file to the
+// vocabulary. String and character literals are not code, they may contain
+// misspelled words just like comments.
+func CollectIdentifiers(source string, commentStyle CommentStyle, cfg *Config, vocabulary *Vocabulary) {
+	lexer := configuredLexer(source, commentStyle, cfg)
+	lexer.SetMarkLiterals(true)
+	inComment := false
+	for {
+		tok := lexer.Next()
+		switch tok.Kind() {
+		case TokenKind.Code:
+			if inComment || tok.IsLiteral() {
+				break
+			}
+			for _, identifier := range identifierPattern.FindAllString(tok.text, -1) {
+				if IsWord(identifier) {
+					vocabulary.Add(identifier)
+				}
+			}
+
+		case TokenKind.CommentBegin:
+			inComment = true
+
+		case TokenKind.CommentEnd:
+			inComment = false
+
+		case TokenKind.EOF:
+			return
+		}
+	}
+}
+
+// configuredLexer creates a lexer with the options from the config applied.
+func configuredLexer(source string, commentStyle CommentStyle, cfg *Config) Lexer {
+	if !cfg.General.SkipRegions {
+		commentStyle.SkipRegions = nil
+	}
+	lexer := NewLexer(source, commentStyle)
+	lexer.SetSkip(cfg.Skip)
+	lexer.SetLanguages(func(language string) CommentStyle {
+		if name := cfg.LanguageStyle(language); name.IsSome() {
+			style := cfg.Styles[name.Unwrap()]
+			if !cfg.General.SkipRegions {
+				style.SkipRegions = nil
+			}
+			return style
+		}
+		return CommentStyle{}
+	})
+	return lexer
+}
+
 func Parse(
 	fileName, source string,
 	commentStyle CommentStyle,
-	speller aspell.Speller,
+	speller Dictionaries,
 	cfg *Config,
 	ignoreList *IgnoreList,
+	counts *WordCounts,
 	useDefaultCommentColor bool,
 ) sf.SourceFile {
-	_lexer := NewLexer(source, commentStyle)
-	lexer := NewPeekable[Token](&_lexer)
+	lexer := configuredLexer(source, commentStyle, cfg)
+	return parseTokens(
+		fileName,
+		&lexer,
+		commentStyle,
+		speller,
+		cfg,
+		ignoreList,
+		counts,
+		useDefaultCommentColor,
+	)
+}
+
+// commentContent passes on the tokens of a lexer whose source begins with a
+// comment token that is not part of the text.
+type commentContent struct {
+	lexer *Lexer
+	// The number of bytes of the comment token that are not removed yet.
+	begin int
+}
+
+func (self *commentContent) Next() Token {
+	for {
+		tok := self.lexer.Next()
+		if self.begin == 0 || tok.Kind() != TokenKind.Code {
+			return tok
+		}
+		length := util.Min(self.begin, len(tok.text))
+		tok.text = tok.text[length:]
+		self.begin -= length
+		if len(tok.text) != 0 {
+			return tok
+		}
+	}
+}
+
+// ParseComment parses text as the content of a comment of the given style, so
+// its words are found the same way as in a file of that style.
+func ParseComment(
+	text string,
+	commentStyle CommentStyle,
+	speller Dictionaries,
+	cfg *Config,
+	ignoreList *IgnoreList,
+	counts *WordCounts,
+) sf.SourceFile {
+	begin := ""
+	switch {
+	case commentStyle.Prose:
+	case len(commentStyle.Line) != 0:
+		begin = stripLineStart(commentStyle.Line[0])
+	case len(commentStyle.BlockBegin) != 0 && !commentStyle.BlockRegex:
+		begin = commentStyle.BlockBegin[0]
+	default:
+		commentStyle = CommentStyle{Prose: true}
+	}
+	lexer := configuredLexer(begin+text, commentStyle, cfg)
+	tokens := commentContent{&lexer, len(begin)}
+	return parseTokens("", &tokens, commentStyle, speller, cfg, ignoreList, counts, false)
+}
+
+// spanPunctuation are the characters after a word that belong to its comment
+// span.
+const spanPunctuation = ".,;:!?)"
+
+// parseTokens creates the source file from the tokens of the given iterator.
+func parseTokens(
+	fileName string,
+	tokens Iterator[Token],
+	commentStyle CommentStyle,
+	speller Dictionaries,
+	cfg *Config,
+	ignoreList *IgnoreList,
+	counts *WordCounts,
+	useDefaultCommentColor bool,
+) sf.SourceFile {
+	lexer := NewPeekable(tokens)
 	tb := tui.NewTextBuffer(cfg.General.TabSize)
 	words := []sf.Word{}
 	inComment := false
+	// Whether words in the current comment are checked.
+	checkComment := true
 	dimCode := cfg.General.DimCode
 	tb.SetStyle(tcell.StyleDefault.Dim(dimCode))
 	// Compiling these for every file is fine since we always have the overhead
@@ -202,37 +341,119 @@ func Parse(
 	}
 	commentRanges := []CommentRange{}
 	var commentBegin tui.SliceIndex
+	grammar := newGrammarChecker(&cfg.Grammar, commentStyle)
+	spans := []sf.CommentSpan{}
+	// The comment span of the current line, First is negative if there is none.
+	span := sf.CommentSpan{First: -1}
+	// The punctuation after the last word of the span.
+	trailing := -1
+	// extendSpan extends the span to the slice, the slices between words are
+	// included that way but not the ones after the last word.
+	extendSpan := func(index tui.SliceIndex) {
+		if span.First < 0 {
+			span = sf.CommentSpan{Line: index.Line(), First: index.Slice()}
+		}
+		span.Last = index.Slice()
+	}
+	closeSpan := func() {
+		if span.First >= 0 {
+			span.Last = util.Max(span.Last, trailing)
+			spans = append(spans, span)
+		}
+		span.First = -1
+		trailing = -1
+	}
+	// addWord adds a word of a comment and checks it.
+	addWord := func(word string) {
+		idx := tb.AddSlice(word)
+		extendSpan(idx)
+		if !checkComment {
+			grammar.reset()
+			return
+		}
+		finding := grammar.check(word, idx)
+		ignored := ignoreList.Ignore(word) || !Filter(word, filters)
+		misspelled := !ignored && IsWord(word) && !speller.Check(word)
+		found := None[sf.Word]()
+		if !ignored && finding.IsSome() &&
+			(!misspelled || finding.Unwrap().Kind == sf.WordKind.Locale) {
+			// The spelling variant explains why a word is misspelled.
+			found = finding
+		} else if misspelled {
+			found = Some(sf.NewWord(word, nil, idx))
+		}
+		if !misspelled {
+			counts.Add(word)
+		}
+		found.Then(func(w sf.Word) {
+			w.Dictionaries = speller
+			words = append(words, w)
+		})
+	}
 
 loop:
 	for {
 		tok := lexer.Next()
-		switch tok.kind {
+		switch tok.Kind() {
 		case TokenKind.Code:
-			tb.AddTabbedSlice(tok.text)
+			text := tok.text
+			if span.First >= 0 {
+				// Punctuation belongs to the span but not the end of the
+				// comment, which may be in the same token.
+				end := len(text) - len(strings.TrimLeft(text, spanPunctuation))
+				if end > 0 {
+					index := tb.AddSlice(text[:end])
+					trailing = index.Slice()
+					text = text[end:]
+				}
+			}
+			grammar.separator(tok.text[:len(tok.text)-len(text)])
+			if !inComment {
+				tb.AddTabbedSlice(text)
+				grammar.separator(text)
+				break
+			}
+			for _, part := range splitLetters(text) {
+				if isLetter(part) && grammar.checksLetters() {
+					addWord(part)
+				} else if isLetter(part) {
+					// Not a word but still part of the comment text.
+					extendSpan(tb.AddSlice(part))
+					grammar.reset()
+				} else {
+					tb.AddTabbedSlice(part)
+					grammar.separator(part)
+				}
+			}
 
 		case TokenKind.CommentWord:
 			before, word, after := TrimSymbols(tok.text)
 			if len(before) > 0 {
-				tb.AddSlice(before)
+				extendSpan(tb.AddSlice(before))
+				grammar.separator(before)
 			}
 			if len(word) > 0 {
-				idx := tb.AddSlice(word)
-				if IsWord(word) &&
-					!ignoreList.Ignore(word) &&
-					!speller.Check(word) &&
-					Filter(word, filters) {
-					words = append(words, sf.NewWord(word, nil, idx))
-				}
+				addWord(word)
 			}
 			if len(after) > 0 {
-				tb.AddSlice(after)
+				extendSpan(tb.AddSlice(after))
+				grammar.separator(after)
+			}
+
+		case TokenKind.Pragma:
+			names := strings.Split(tok.text[len(string(dictionaryPragma)):], ",")
+			if selected, ok := speller.Select(names); ok {
+				speller = selected
 			}
+			extendSpan(tb.AddSlice(tok.text))
+			grammar.reset()
 
 		case TokenKind.CommentBegin:
 			if useDefaultCommentColor {
 				tb.SetStyle(commentColor)
 			}
 			inComment = true
+			checkComment = !cfg.General.DocOnly || tok.IsDoc()
 			commentBegin = tb.NextIndex()
 
 		case TokenKind.CommentEnd:
@@ -240,6 +461,7 @@ loop:
 				tb.SetStyle(tcell.StyleDefault.Dim(dimCode))
 			}
 			inComment = false
+			closeSpan()
 			range_ := CommentRange{commentBegin, tb.NextIndex()}
 			// no clue why this is needed but it seems to always work.
 			range_.begin.OffsetLine(-1)
@@ -248,17 +470,19 @@ loop:
 
 		case TokenKind.Style:
 			style := tui.Ansi2Style(tok.text)
-			comment := (inComment || lexer.Peek().kind == TokenKind.CommentBegin) &&
-				lexer.Peek().kind != TokenKind.CommentEnd
+			comment := (inComment || lexer.Peek().Kind() == TokenKind.CommentBegin) &&
+				lexer.Peek().Kind() != TokenKind.CommentEnd
 			if dimCode && !comment {
 				style = style.Dim(true)
 			}
 			tb.SetStyle(style)
 
 		case TokenKind.Newline:
+			closeSpan()
 			tb.Newline()
 
 		case TokenKind.EOF:
+			closeSpan()
 			break loop
 		}
 	}
@@ -268,13 +492,16 @@ loop:
 	// although it means there is no visual difference between a file with or
 	// without a final newline but this is not a text editor so who cares.
 	tb.RemoveLastLineIfEmpty()
-	if cfg.General.FilterCommentedCode {
-		words = FilterCommentedCode(words, &tb, commentRanges, commentStyle.Line)
+	if cfg.General.FilterCommentedCode && !commentStyle.Prose {
+		words = FilterCommentedCode(words, &tb, commentRanges, util.Map(commentStyle.Line, stripLineStart))
 	}
 	// We need to set the slice pointers after building the text buffer as these
 	// point into slices which may be reallocated during creation.
 	for i := range words {
 		words[i].Slice = tb.GetSlice(words[i].Index)
 	}
-	return sf.NewSourceFile(fileName, tb, words)
+	source := sf.NewSourceFile(fileName, tb, words)
+	source.SetCommentSpans(spans)
+	source.SetCommentStyle(commentStyle)
+	return source
 }
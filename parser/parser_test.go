@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+	"github.com/JaMo42/spellcheck_comments/tui"
+)
+
+func TestCollectIdentifiers(t *testing.T) {
+	cfg := DefaultConfig()
+	vocabulary := NewVocabulary()
+	source := "// NotAnIdentifier\nfunc (self *TextBuffer) add_slice(x int) { /* tcel */ }\n" +
+		"print(\"Recieved\", 'ab', \"multi\\\nlien\") // after\n"
+	CollectIdentifiers(source, cCommentStyle, &cfg, vocabulary)
+	for _, word := range []string{"func", "self", "TextBuffer", "add_slice", "int", "print"} {
+		if !vocabulary.Contains(word) {
+			t.Errorf("%s: not collected", word)
+		}
+	}
+	for _, word := range []string{"NotAnIdentifier", "tcel", "x", "Recieved", "ab", "multi", "lien", "after"} {
+		if vocabulary.Contains(word) {
+			t.Errorf("%s: collected", word)
+		}
+	}
+}
+
+func TestCommentSpans(t *testing.T) {
+	cfg := DefaultConfig()
+	ignoreList := NewIgnoreList(false)
+	source := "x := 1 // Some text,  here.  \n/* A block\n   comment. */\ny := 2\n// The end.\n"
+	file := Parse("", source, cCommentStyle, Dictionaries{}, &cfg, &ignoreList, NewWordCounts(), false)
+	expected := []string{"Some text,  here.", "A block", "comment.", "", "The end."}
+	for line, text := range expected {
+		span := None[sf.CommentSpan]()
+		file.Text().ForEachInLine(line, func(_ string, index tui.SliceIndex) {
+			if !span.IsSome() {
+				span = file.CommentSpan(index)
+			}
+		})
+		if !span.IsSome() {
+			if len(text) != 0 {
+				t.Errorf("line %d: no span", line)
+			}
+		} else if actual := file.SpanText(span.Unwrap()); actual != text {
+			t.Errorf("line %d: expected %q, got %q", line, text, actual)
+		}
+	}
+}
+
+func TestSingleLetters(t *testing.T) {
+	cfg := DefaultConfig()
+	ignoreList := NewIgnoreList(false)
+	source := "x := y // a a b-c e.g. 3f9 cat\n"
+	findings := func() []string {
+		file := Parse("", source, cCommentStyle, Dictionaries{}, &cfg, &ignoreList, NewWordCounts(), false)
+		if text := file.String(); text != source {
+			t.Errorf("text changed: %q", text)
+		}
+		result := []string{}
+		for _, word := range file.Words() {
+			result = append(result, fmt.Sprintf("%d:%s", word.Kind, word.Original))
+		}
+		return result
+	}
+	// Single letters are never misspelled.
+	expected := []string{"0:b-c", "0:cat"}
+	if got := findings(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	cfg.Grammar.RepeatedWords = true
+	expected = []string{"1:a", "0:b-c", "0:cat"}
+	if got := findings(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q with repeated words", got, expected)
+	}
+}
+
+func TestParseComment(t *testing.T) {
+	cfg := DefaultConfig()
+	ignoreList := NewIgnoreList(false)
+	cases := []struct {
+		style CommentStyle
+		text  string
+		words []string
+	}{
+		{cCommentStyle, "alot of `wrds` at https://exmaple.com", []string{"alot", "of", "at"}},
+		{CommentStyle{BlockBegin: []string{"<!--"}, BlockEnd: []string{"-->"}}, "see `teh` docs", []string{"see", "docs"}},
+		{CommentStyle{Prose: true}, "# Teh title", []string{"Teh", "title"}},
+	}
+	for _, c := range cases {
+		file := ParseComment(c.text, c.style, Dictionaries{}, &cfg, &ignoreList, NewWordCounts())
+		if text := file.String(); text != c.text {
+			t.Errorf("%q: text changed to %q", c.text, text)
+		}
+		words := []string{}
+		for _, word := range file.Words() {
+			words = append(words, word.Original)
+		}
+		if !reflect.DeepEqual(words, c.words) {
+			t.Errorf("%q: got %q, expected %q", c.text, words, c.words)
+		}
+	}
+}
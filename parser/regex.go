@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+// TokenTemplate is the template for a token that is created when a regex
+// transition is taken. The text may reference the groups of the regex using
+// `$1` or `${name}`. If the text begins with `^` the token only matches at the
+// beginning of a line, ignoring indentation.
+type TokenTemplate struct {
+	Text    string
+	ToState State
+}
+
+type regexTransition struct {
+	re        *regexp.Regexp
+	toState   State
+	templates []TokenTemplate
+	// Whether the regex only matches at the beginning of a line.
+	lineStart bool
+	// A match begins with one of these, so the regex is only run where one of
+	// them is found.
+	prefixes [][]rune
+}
+
+// newRegexTransition creates a regex transition. Like token templates, a
+// pattern beginning with `^` only matches at the beginning of a line, ignoring
+// indentation. The pattern must begin with fixed text or a character class.
+func newRegexTransition(pattern string, toState State, templates []TokenTemplate) *regexTransition {
+	text, lineStart := parseTemplateText(pattern)
+	prefixes := util.RegexPrefixes(text)
+	if prefixes == nil {
+		panic(fmt.Sprintf("regex %q can begin with any text", text))
+	}
+	trans := &regexTransition{compileAnchored(text), toState, templates, lineStart, nil}
+	for _, prefix := range prefixes {
+		trans.prefixes = append(trans.prefixes, []rune(prefix))
+	}
+	return trans
+}
+
+// firstRunes returns the runes a match can begin with.
+func (self *regexTransition) firstRunes() []rune {
+	runes := make([]rune, 0, len(self.prefixes))
+	for _, prefix := range self.prefixes {
+		runes = append(runes, prefix[0])
+	}
+	return util.Deduplicate(runes)
+}
+
+// atPrefix returns true if input begins with one of the prefixes.
+func (self *regexTransition) atPrefix(input []rune) bool {
+	for _, prefix := range self.prefixes {
+		if hasPrefix(input, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// runeReader reads from a rune slice.
+type runeReader struct {
+	runes []rune
+	pos   int
+}
+
+func (self *runeReader) ReadRune() (rune, int, error) {
+	if self.pos == len(self.runes) {
+		return 0, 0, io.EOF
+	}
+	r := self.runes[self.pos]
+	self.pos++
+	return r, utf8.RuneLen(r), nil
+}
+
+// runeCount returns how many runes of input make up the first bytes bytes.
+func runeCount(input []rune, bytes int) int {
+	count := 0
+	for bytes > 0 {
+		bytes -= utf8.RuneLen(input[count])
+		count++
+	}
+	return count
+}
+
+// match matches the regex at the beginning of input. Returns the length of
+// the match in runes and the tokens created from the templates. A length of 0
+// means the regex did not match.
+func (self *regexTransition) match(input []rune, atLineStart bool) (int, []TokenTemplate) {
+	if self.lineStart && !atLineStart || !self.atPrefix(input) {
+		return 0, nil
+	}
+	loc := self.re.FindReaderSubmatchIndex(&runeReader{input, 0})
+	if loc == nil || loc[1] == 0 {
+		return 0, nil
+	}
+	length := runeCount(input, loc[1])
+	matched := string(input[:length])
+	tokens := make([]TokenTemplate, 0, len(self.templates))
+	for _, template := range self.templates {
+		text := self.re.ExpandString(nil, template.Text, matched, loc)
+		tokens = append(tokens, TokenTemplate{string(text), template.ToState})
+	}
+	return length, tokens
+}
+
+// compileAnchored compiles a pattern so it only matches at the beginning of
+// the input.
+func compileAnchored(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(`\A(?:` + pattern + `)`)
+}
+
+// parseTemplateText splits the line start marker off a template.
+func parseTemplateText(text string) (string, bool) {
+	if strings.HasPrefix(text, "^") {
+		return text[1:], true
+	}
+	return text, false
+}
+
+// stripLineStart returns the text of a token without the line start marker.
+func stripLineStart(token string) string {
+	text, _ := parseTemplateText(token)
+	return text
+}
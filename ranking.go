@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/util"
+)
+
+const (
+	// Cost of each position in the order given by the dictionaries, so their
+	// order decides between otherwise equal suggestions.
+	rankOrderWeight = 0.1
+	// Bonus for each doubling of the occurrences of a word in the comments.
+	rankFrequencyWeight = 0.25
+	// Bonus for each doubling of the times a word was chosen before, so a
+	// word chosen very often can't outweigh everything else.
+	rankChoiceWeight = 1.0
+)
+
+// suggestionRanker orders the suggestions of the dictionaries by how likely
+// they are the intended word.
+type suggestionRanker struct {
+	counts *WordCounts
+	// How often the words were chosen as replacements, in lower case.
+	choices map[string]int
+	// Whether a word was chosen since the choices were loaded.
+	changed bool
+}
+
+func newSuggestionRanker(counts *WordCounts) suggestionRanker {
+	return suggestionRanker{counts, make(map[string]int), false}
+}
+
+// choose remembers that a word was chosen as a replacement.
+func (self *suggestionRanker) choose(word string) {
+	self.choices[strings.ToLower(word)]++
+	self.changed = true
+}
+
+// load adds the choices saved in a file, each line contains a word followed by
+// how often it was chosen.
+func (self *suggestionRanker) load(filename string) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		count, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			continue
+		}
+		word := strings.Join(fields[:len(fields)-1], " ")
+		self.choices[strings.ToLower(word)] += count
+	}
+}
+
+// save writes all choices to a file if a word was chosen since they were
+// loaded.
+func (self *suggestionRanker) save(filename string) error {
+	if !self.changed {
+		return nil
+	}
+	lines := make([]string, 0, len(self.choices))
+	for word, count := range self.choices {
+		lines = append(lines, fmt.Sprintf("%s %d\n", word, count))
+	}
+	sort.Strings(lines)
+	return os.WriteFile(filename, []byte(strings.Join(lines, "")), 0600)
+}
+
+// score returns the score of a suggestion for a word, lower is better.
+func (self *suggestionRanker) score(word, suggestion string, position int) float64 {
+	score := util.KeyboardDistance(word, suggestion)
+	score += rankOrderWeight * float64(position)
+	score -= rankFrequencyWeight * math.Log2(1+float64(self.counts.Count(suggestion)))
+	score -= rankChoiceWeight * math.Log2(1+float64(self.choices[strings.ToLower(suggestion)]))
+	return score
+}
+
+// rank sorts the suggestions for a word and changes the case of lower case
+// suggestions to match it.
+func (self *suggestionRanker) rank(word string, suggestions []string) []string {
+	scores := make(map[string]float64, len(suggestions))
+	for i, suggestion := range suggestions {
+		scores[suggestion] = self.score(word, suggestion, i)
+	}
+	ranked := util.Copy(suggestions)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] < scores[ranked[j]]
+	})
+	result := make([]string, 0, len(ranked))
+	seen := make(map[string]bool)
+	for _, suggestion := range ranked {
+		suggestion = util.FollowCase(suggestion, word)
+		if !seen[suggestion] {
+			seen[suggestion] = true
+			result = append(result, suggestion)
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+)
+
+func TestRankSuggestions(t *testing.T) {
+	counts := NewWordCounts()
+	for i := 0; i < 8; i++ {
+		counts.Add("buffer")
+	}
+	ranker := newSuggestionRanker(counts)
+	ranker.choose("lint")
+	cases := []struct {
+		word        string
+		suggestions []string
+		expected    []string
+	}{
+		{"Recieve", []string{"relieve", "receive", "Receive"}, []string{"Receive", "Relieve"}},
+		{"RECIEVE", []string{"relieve", "receive"}, []string{"RECEIVE", "RELIEVE"}},
+		{"teh", []string{"tech", "the", "tea"}, []string{"the", "tech", "tea"}},
+		{"bufer", []string{"buffet", "buffer"}, []string{"buffer", "buffet"}},
+		{"lnt", []string{"lit", "lint"}, []string{"lint", "lit"}},
+		{"Iphone", []string{"iPhone", "phone"}, []string{"iPhone", "Phone"}},
+	}
+	for _, c := range cases {
+		if got := ranker.rank(c.word, c.suggestions); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: got %v, expected %v", c.word, got, c.expected)
+		}
+	}
+}
+
+func TestSavedChoices(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "choices")
+	suggestions := []string{"lit", "lint"}
+	ranker := newSuggestionRanker(NewWordCounts())
+	if got := ranker.rank("lnt", suggestions); got[0] != "lit" {
+		t.Fatalf("got %v without choices", got)
+	}
+	ranker.choose("Lint")
+	if err := ranker.save(filename); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := newSuggestionRanker(NewWordCounts())
+	reloaded.load(filename)
+	if got := reloaded.rank("lnt", suggestions); !reflect.DeepEqual(got, []string{"lint", "lit"}) {
+		t.Errorf("got %v after reload, expected [lint lit]", got)
+	}
+	reloaded.choose("lint")
+	if err := reloaded.save(filename); err != nil {
+		t.Fatal(err)
+	}
+	merged := newSuggestionRanker(NewWordCounts())
+	merged.load(filename)
+	if merged.choices["lint"] != 2 {
+		t.Errorf("got %d choices for lint, expected 2", merged.choices["lint"])
+	}
+}
+
+func TestChoiceBonusGrowsSlowly(t *testing.T) {
+	ranker := newSuggestionRanker(NewWordCounts())
+	before := ranker.score("lnt", "lint", 0)
+	for i := 0; i < 1000; i++ {
+		ranker.choose("lint")
+	}
+	if bonus := before - ranker.score("lnt", "lint", 0); bonus > 10*rankChoiceWeight {
+		t.Errorf("got a bonus of %f for 1000 choices", bonus)
+	}
+}
+
+func TestChoicesPath(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/data")
+	if path, _ := choicesPath(); path != "/data/spellcheck_comments/choices" {
+		t.Errorf("got %q with XDG_DATA_HOME", path)
+	}
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("HOME", "/home/user")
+	if path, _ := choicesPath(); path != "/home/user/.local/share/spellcheck_comments/choices" {
+		t.Errorf("got %q with HOME", path)
+	}
+}
@@ -7,20 +7,71 @@ import (
 
 	. "github.com/JaMo42/spellcheck_comments/common"
 	"github.com/JaMo42/spellcheck_comments/tui"
+	"github.com/JaMo42/spellcheck_comments/util"
 )
 
 var (
 	sfBuilder strings.Builder
 )
 
+// WordKindType is the underlying type for the values in WordKind.
+type WordKindType int
+
+// WordKind acts as a namespace for the kinds of findings.
+var WordKind = struct {
+	Misspelled WordKindType
+	// The word repeats the word before it.
+	Repeated WordKindType
+	// The word is often confused with the alternatives.
+	Confusable WordKindType
+	// The word is spelled in another variant of the language.
+	Locale WordKindType
+}{0, 1, 2, 3}
+
 type Word struct {
 	Original string
 	Slice    *tui.TextSlice
 	Index    tui.SliceIndex
+	Kind     WordKindType
+	// The words suggested for findings other than misspellings.
+	Alternatives []string
+	// Describes the problem with a word that is not misspelled.
+	Reason string
+	// The dictionaries that were active for the word.
+	Dictionaries Dictionaries
 }
 
 func NewWord(original string, slice *tui.TextSlice, index tui.SliceIndex) Word {
-	return Word{original, slice, index}
+	return Word{original, slice, index, WordKind.Misspelled, nil, "", Dictionaries{}}
+}
+
+// NewFinding creates a word that is not misspelled but has another problem.
+func NewFinding(
+	kind WordKindType,
+	original string,
+	index tui.SliceIndex,
+	alternatives []string,
+	reason string,
+) Word {
+	return Word{original, nil, index, kind, alternatives, reason, Dictionaries{}}
+}
+
+// Encoder turns the text of a file back into the file contents, for files
+// whose contents are not checked as they are (i.e. notebooks).
+type Encoder interface {
+	Encode(text string) ([]byte, error)
+	// Original returns the original file contents.
+	Original() []byte
+}
+
+// CommentSpan is the text of a comment in a line, from its first to its last
+// word. First and Last are inclusive slice indices.
+type CommentSpan struct {
+	Line, First, Last int
+}
+
+func (self *CommentSpan) contains(index tui.SliceIndex) bool {
+	return index.Line() == self.Line && index.Slice() >= self.First && index.Slice() <= self.Last
 }
 
 type SourceFile struct {
@@ -28,10 +79,164 @@ type SourceFile struct {
 	tb       tui.TextBuffer
 	words    []Word
 	nextWord int
+	encoder  Encoder
+	spans    []CommentSpan
+	style    CommentStyle
 }
 
 func NewSourceFile(name string, tb tui.TextBuffer, words []Word) SourceFile {
-	return SourceFile{name, tb, words, 0}
+	return SourceFile{name, tb, words, 0, nil, nil, CommentStyle{}}
+}
+
+// SetCommentSpans sets the comment spans of the file.
+func (self *SourceFile) SetCommentSpans(spans []CommentSpan) {
+	self.spans = spans
+}
+
+// CommentSpan returns the comment span containing the slice.
+func (self *SourceFile) CommentSpan(index tui.SliceIndex) Optional[CommentSpan] {
+	for _, span := range self.spans {
+		if span.contains(index) {
+			return Some(span)
+		}
+	}
+	return None[CommentSpan]()
+}
+
+// SpanText returns the text of a comment span.
+func (self *SourceFile) SpanText(span CommentSpan) string {
+	var builder strings.Builder
+	self.tb.ForEachInLine(span.Line, func(s string, index tui.SliceIndex) {
+		if span.contains(index) {
+			builder.WriteString(s)
+		}
+	})
+	return builder.String()
+}
+
+// LineState is a copy of everything ReplaceSpan changes.
+type LineState struct {
+	line   int
+	slices []tui.TextSlice
+	words  []Word
+	spans  []CommentSpan
+}
+
+func (self *LineState) Line() int {
+	return self.line
+}
+
+// SaveLine copies the state of a line so it can be restored after replacing a
+// span in it.
+func (self *SourceFile) SaveLine(line int) LineState {
+	return LineState{
+		line:   line,
+		slices: self.tb.LineSlices(line),
+		words:  append([]Word{}, self.words...),
+		spans:  append([]CommentSpan{}, self.spans...),
+	}
+}
+
+// RestoreLine restores the state of a line.
+func (self *SourceFile) RestoreLine(state LineState) {
+	self.tb.SetLineSlices(state.line, state.slices)
+	self.words = state.words
+	self.spans = state.spans
+	self.updateSlices(state.line)
+}
+
+// updateSlices updates the slice pointers of the words in a line.
+func (self *SourceFile) updateSlices(line int) {
+	for i := range self.words {
+		if self.words[i].Index.Line() == line {
+			self.words[i].Slice = self.tb.GetSlice(self.words[i].Index)
+		}
+	}
+}
+
+// ReplaceSpan replaces the text of a comment span with the first line of
+// edited, its words replace the words in the span. Returns the id of the
+// first word after the start of the span.
+func (self *SourceFile) ReplaceSpan(span CommentSpan, edited *SourceFile) int {
+	texts := []string{}
+	if edited.tb.LineCount() != 0 {
+		edited.tb.ForEachInLine(0, func(s string, _ tui.SliceIndex) {
+			texts = append(texts, s)
+		})
+	}
+	if len(texts) == 0 {
+		// Keep a slice so the span is not empty.
+		texts = append(texts, "")
+	}
+	shift := len(texts) - (span.Last - span.First + 1)
+	self.tb.ReplaceSlices(span.Line, span.First, span.Last, texts)
+	words := []Word{}
+	first := -1
+	insertEdited := func() {
+		first = len(words)
+		for _, w := range edited.words {
+			if w.Index.Line() == 0 {
+				w.Index = tui.NewSliceIndex(span.Line, span.First+w.Index.Slice())
+				words = append(words, w)
+			}
+		}
+	}
+	for _, word := range self.words {
+		line, slice := word.Index.Line(), word.Index.Slice()
+		if first < 0 && (line > span.Line || (line == span.Line && slice >= span.First)) {
+			insertEdited()
+		}
+		if line == span.Line && slice > span.Last {
+			word.Index = tui.NewSliceIndex(line, slice+shift)
+		} else if span.contains(word.Index) {
+			continue
+		}
+		words = append(words, word)
+	}
+	if first < 0 {
+		insertEdited()
+	}
+	self.words = words
+	for i := range self.spans {
+		other := &self.spans[i]
+		if other.Line == span.Line && other.First > span.Last {
+			other.First += shift
+			other.Last += shift
+		} else if *other == span {
+			other.Last = span.First + len(texts) - 1
+		}
+	}
+	self.updateSlices(span.Line)
+	return first
+}
+
+// SetEncoder sets the encoder used to create the file contents.
+func (self *SourceFile) SetEncoder(encoder Encoder) {
+	self.encoder = encoder
+}
+
+// SetCommentStyle sets the comment style the file was parsed with.
+func (self *SourceFile) SetCommentStyle(style CommentStyle) {
+	self.style = style
+}
+
+// CommentStyle returns the comment style the file was parsed with.
+func (self *SourceFile) CommentStyle() CommentStyle {
+	return self.style
+}
+
+// Encoder returns the encoder of the file, or nil if the text is the file
+// contents.
+func (self *SourceFile) Encoder() Encoder {
+	return self.encoder
+}
+
+// Bytes returns the file contents.
+func (self *SourceFile) Bytes() ([]byte, error) {
+	if self.encoder != nil {
+		return self.encoder.Encode(self.String())
+	}
+	return []byte(self.String()), nil
 }
 
 func (self *SourceFile) Text() *tui.TextBuffer {
@@ -50,6 +255,11 @@ func (self *SourceFile) Words() []Word {
 	return self.words
 }
 
+// KeepWords removes all words for which keep returns false.
+func (self *SourceFile) KeepWords(keep func(Word) bool) {
+	self.words = util.StableFilter(self.words, keep)
+}
+
 func (self *SourceFile) NextWord() Optional[Word] {
 	if self.nextWord == len(self.words) {
 		return None[Word]()
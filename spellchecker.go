@@ -1,13 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
-	"github.com/trustmaster/go-aspell"
 	"golang.org/x/text/cases"
 
 	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/parser"
 	. "github.com/JaMo42/spellcheck_comments/source_file"
 	"github.com/JaMo42/spellcheck_comments/tui"
 	"github.com/JaMo42/spellcheck_comments/util"
@@ -20,12 +23,15 @@ type ActionUndo struct{}
 type ActionSkip struct{}
 type ActionExit struct{}
 type ActionAbort struct{}
+type ActionEdit struct{}
 
 type Layout interface {
 	Configure(*Config)
 	SetSource(*SourceFile)
 	Show(tui.SliceIndex)
 	SetSuggestions([]string)
+	// SetMessage shows what is wrong with a word that is not misspelled.
+	SetMessage(string)
 	ArrowReceiver() tui.ArrowReceiver
 	MouseReceivers() []tui.MouseReceiver
 }
@@ -36,16 +42,25 @@ type UndoReplacement struct {
 
 type UndoIgnore struct {
 	all  bool
+	kind WordKindType
 	word string
 }
 
 type UndoSkip struct{}
 
+type UndoRemove struct {
+	slices []tui.SliceIndex
+}
+
 type UndoReplaceAll struct {
 	startIndex tui.SliceIndex
 	from       string
 }
 
+type UndoEdit struct {
+	edit lineEdit
+}
+
 type UndoEventBase struct {
 	fileId int
 	wordId int
@@ -56,9 +71,15 @@ type SpellChecker struct {
 	scr             tcell.Screen
 	ui              tui.Tui
 	layout          Layout
-	speller         aspell.Speller
 	ignore          map[string]bool
-	replacements    map[string]string
+	ignoreFindings  map[findingKey]bool
+	replacements    map[string]Replacement
+	automatic       *Replacements
+	saved           *Replacements
+	ranker          suggestionRanker
+	cfg             *Config
+	ignoreList      *IgnoreList
+	counts          *WordCounts
 	changed         bool
 	discardAll      bool
 	doBackup        bool
@@ -70,7 +91,12 @@ type SpellChecker struct {
 }
 
 func NewSpellChecker(
-	scr tcell.Screen, speller aspell.Speller, cfg *Config,
+	scr tcell.Screen,
+	cfg *Config,
+	ignoreList *IgnoreList,
+	counts *WordCounts,
+	automatic *Replacements,
+	saved *Replacements,
 ) SpellChecker {
 	var layout Layout
 	switch cfg.General.Layout {
@@ -101,9 +127,15 @@ func NewSpellChecker(
 		scr:             scr,
 		ui:              ui,
 		layout:          layout,
-		speller:         speller,
 		ignore:          make(map[string]bool),
-		replacements:    make(map[string]string),
+		ignoreFindings:  make(map[findingKey]bool),
+		replacements:    make(map[string]Replacement),
+		automatic:       automatic,
+		saved:           saved,
+		ranker:          newSuggestionRanker(counts),
+		cfg:             cfg,
+		ignoreList:      ignoreList,
+		counts:          counts,
 		doBackup:        cfg.General.Backup,
 		caser:           caser,
 		suggestionCount: cfg.General.Suggestions,
@@ -118,16 +150,74 @@ func (self *SpellChecker) transform(word string) string {
 	return word
 }
 
-// replaceAllInFile replaces all occurrences of a word in the current file.
-// from should already be transformed.
-func (self *SpellChecker) replaceAllInFile(file *FileContext, from string, to string, after tui.SliceIndex) {
+// findingKey identifies the words ignored with "Ignore all" for findings other
+// than misspellings.
+type findingKey struct {
+	kind WordKindType
+	word string
+}
+
+// isIgnored returns true if the finding for the word is ignored.
+func (self *SpellChecker) isIgnored(word *Word) bool {
+	original := self.transform(word.Original)
+	if word.Kind == WordKind.Misspelled {
+		return self.ignore[original]
+	}
+	return self.ignoreFindings[findingKey{word.Kind, original}]
+}
+
+// setIgnored sets whether all findings of the kind for a transformed word are
+// ignored.
+func (self *SpellChecker) setIgnored(kind WordKindType, word string, ignored bool) {
+	if kind == WordKind.Misspelled {
+		self.ignore[word] = ignored
+	} else {
+		self.ignoreFindings[findingKey{kind, word}] = ignored
+	}
+	if !ignored {
+		delete(self.ignore, word)
+		delete(self.ignoreFindings, findingKey{kind, word})
+	}
+}
+
+// suggestions returns the suggestions for a word.
+func (self *SpellChecker) suggestions(word *Word) []string {
+	switch word.Kind {
+	case WordKind.Repeated:
+		return []string{fmt.Sprintf("Remove %q", word.Original)}
+	case WordKind.Confusable, WordKind.Locale:
+		return word.Alternatives
+	}
+	suggestions := self.ranker.rank(word.Original, word.Dictionaries.Suggest(word.Original))
+	self.saved.Get(word.Original).Then(func(replacement string) {
+		suggestions = withFirst(suggestions, replacement)
+	})
+	return suggestions
+}
+
+// replaceAllInFile replaces all occurrences of a misspelled word in the current
+// file. from should already be transformed.
+func (self *SpellChecker) replaceAllInFile(file *FileContext, from string, to Replacement, after tui.SliceIndex) {
 	for _, word := range file.Source().Words() {
-		if word.Index.IsSameOrAfter(after) && self.transform(word.Original) == from {
-			file.Change(word.Index, to)
+		if word.Kind == WordKind.Misspelled &&
+			word.Index.IsSameOrAfter(after) &&
+			self.transform(word.Original) == from {
+			file.Change(word.Index, to.Apply(word.Original))
 		}
 	}
 }
 
+// parseEdited parses the edited text of a comment in a file with the
+// dictionaries of the word it was edited at.
+func (self *SpellChecker) parseEdited(file *FileContext, text string, dictionaries Dictionaries) SourceFile {
+	// The comment is known to be checked.
+	cfg := *self.cfg
+	cfg.General.DocOnly = false
+	return parser.ParseComment(
+		text, file.Source().CommentStyle(), dictionaries, &cfg, self.ignoreList, self.counts,
+	)
+}
+
 func (self *SpellChecker) setFile(id int) *FileContext {
 	self.currentFile = id
 	self.layout.SetSource(self.files[id].Source())
@@ -139,6 +229,9 @@ func (self *SpellChecker) AddFile(sf SourceFile) {
 	self.files = append(self.files, NewFileContext(sf))
 	self.currentFile = len(self.files) - 1
 	file := &self.files[len(self.files)-1]
+	if len(file.ApplyReplacements(self.automatic)) != 0 {
+		self.changed = true
+	}
 	for from, to := range self.replacements {
 		self.replaceAllInFile(file, from, to, tui.NewSliceIndex(0, 0))
 	}
@@ -150,15 +243,23 @@ func (self *SpellChecker) doUndo(event UndoEventBase) (int, int) {
 	evWordId := event.wordId
 	switch event := event.kind.(type) {
 	case UndoReplacement:
-		file.RemoveChange(event.slice, file.Word(evWordId).Original)
+		file.RemoveChange(event.slice)
 
 	case UndoIgnore:
 		if event.all {
-			delete(self.ignore, event.word)
+			self.setIgnored(event.kind, event.word, false)
 		}
 
 	case UndoSkip:
 
+	case UndoRemove:
+		for _, slice := range event.slices {
+			file.RemoveChange(slice)
+		}
+
+	case UndoEdit:
+		self.files[evFileId].UndoEdit(event.edit)
+
 	case UndoReplaceAll:
 		delete(self.replacements, event.from)
 		for fileId := evFileId; fileId < len(self.files); fileId++ {
@@ -168,8 +269,10 @@ func (self *SpellChecker) doUndo(event UndoEventBase) (int, int) {
 			}
 			file := self.files[fileId]
 			for _, word := range file.Source().Words() {
-				if word.Index.IsSameOrAfter(start) && self.transform(word.Original) == event.from {
-					file.RemoveChange(word.Index, word.Original)
+				if word.Kind == WordKind.Misspelled &&
+					word.Index.IsSameOrAfter(start) &&
+					self.transform(word.Original) == event.from {
+					file.RemoveChange(word.Index)
 				}
 			}
 		}
@@ -180,6 +283,18 @@ func (self *SpellChecker) doUndo(event UndoEventBase) (int, int) {
 	return evFileId, evWordId
 }
 
+// withFirst moves or inserts the given suggestion to the beginning of the
+// suggestions.
+func withFirst(suggestions []string, first string) []string {
+	result := []string{first}
+	for _, suggestion := range suggestions {
+		if suggestion != first {
+			result = append(result, suggestion)
+		}
+	}
+	return result
+}
+
 // Run runs the checker until all current files are checked. Returns true if the
 // program should quit.
 func (self *SpellChecker) Run() bool {
@@ -210,15 +325,17 @@ func (self *SpellChecker) Run() bool {
 		}
 
 		word := file.Word(wordId)
-		if self.ignore[self.transform(word.Original)] || file.SliceIsChanged(word.Index) {
+		if self.isIgnored(word) || file.SliceIsChanged(word.Index) {
 			wordId++
 			continue
 		}
-		suggestions := self.speller.Suggest(word.Original)
+		misspelled := word.Kind == WordKind.Misspelled
+		suggestions := self.suggestions(word)
 		if len(suggestions) > self.suggestionCount {
 			suggestions = suggestions[:self.suggestionCount]
 		}
 		self.layout.SetSuggestions(suggestions)
+		self.layout.SetMessage(word.Reason)
 		self.layout.Show(word.Index)
 
 	repeatKey:
@@ -230,10 +347,17 @@ func (self *SpellChecker) Run() bool {
 			if action.index >= len(suggestions) {
 				goto repeatKey
 			}
-			replacement := suggestions[action.index]
-			file.Change(word.Index, replacement)
-			self.speller.Replace(word.Original, replacement)
-			addUndoEvent(UndoReplacement{word.Index})
+			if word.Kind == WordKind.Repeated {
+				addUndoEvent(UndoRemove{file.RemoveWord(word.Index)})
+			} else {
+				replacement := util.FollowCase(suggestions[action.index], word.Original)
+				file.Change(word.Index, replacement)
+				if misspelled {
+					word.Dictionaries.Replace(word.Original, replacement)
+					self.ranker.choose(replacement)
+				}
+				addUndoEvent(UndoReplacement{word.Index})
+			}
 			self.changed = true
 			wordId++
 
@@ -241,12 +365,17 @@ func (self *SpellChecker) Run() bool {
 			var original string
 			if action.all {
 				original = self.transform(word.Original)
-				self.ignore[original] = true
+				self.setIgnored(word.Kind, original, true)
 			}
-			addUndoEvent(UndoIgnore{action.all, original})
+			addUndoEvent(UndoIgnore{action.all, word.Kind, original})
 			wordId++
 
 		case ActionReplace:
+			if action.all && !misspelled {
+				// Replacing all occurrences of a correctly spelled word
+				// is never intended.
+				goto repeatKey
+			}
 			var caption string
 			if action.all {
 				caption = "Replace all"
@@ -256,29 +385,52 @@ func (self *SpellChecker) Run() bool {
 			maybeText := tui.InputBox(
 				self.scr,
 				caption,
-				"Enter replacement",
-				self.speller.Suggest,
+				"Enter replacement ("+ExactPrefix+" to keep its case)",
+				word.Dictionaries.Suggest,
 			)
-			if maybeText.IsSome() && len(maybeText.Unwrap()) > 0 {
+			if maybeText.IsSome() && len(strings.TrimPrefix(maybeText.Unwrap(), ExactPrefix)) > 0 {
 				text := maybeText.Unwrap()
+				to := NewReplacement(text, word.Original)
+				text = to.Apply(word.Original)
 				if action.all {
 					original := self.transform(word.Original)
-					self.replacements[original] = text
+					self.replacements[original] = to
 					for id := fileId; id < fileEnd; id++ {
-						self.replaceAllInFile(&self.files[id], original, text, word.Index)
+						self.replaceAllInFile(&self.files[id], original, to, word.Index)
 					}
 					addUndoEvent(UndoReplaceAll{word.Index, original})
 				} else {
 					file.Change(word.Index, text)
 					addUndoEvent(UndoReplacement{word.Index})
 				}
-				self.speller.Replace(word.Original, text)
+				if misspelled {
+					word.Dictionaries.Replace(word.Original, text)
+					self.ranker.choose(text)
+				}
 				self.changed = true
 				wordId++
 			} else {
 				goto repeatKey
 			}
 
+		case ActionEdit:
+			span := file.Source().CommentSpan(word.Index)
+			if !span.IsSome() {
+				goto repeatKey
+			}
+			original := file.Source().SpanText(span.Unwrap())
+			maybeText := tui.EditBox(self.scr, "Edit comment line", original)
+			if !maybeText.IsSome() || maybeText.Unwrap() == original {
+				goto repeatKey
+			}
+			edited := self.parseEdited(file, maybeText.Unwrap(), word.Dictionaries)
+			edit, first := file.EditSpan(span.Unwrap(), &edited)
+			addUndoEvent(UndoEdit{edit})
+			self.changed = true
+			// The words of the edited text are checked next.
+			wordId = first
+			wordEnd = len(file.Source().Words())
+
 		case ActionUndo:
 			if len(self.undoStack) == 0 {
 				goto repeatKey
@@ -286,6 +438,7 @@ func (self *SpellChecker) Run() bool {
 			var event UndoEventBase
 			event, self.undoStack = util.PopBack(self.undoStack)
 			fileId, wordId = self.doUndo(event)
+			wordEnd = len(file.Source().Words())
 
 		case ActionSkip:
 			addUndoEvent(UndoSkip{})
@@ -306,7 +459,8 @@ func (self *SpellChecker) Run() bool {
 	}
 }
 
-func (self *SpellChecker) Finish() {
+// Finish writes the changed files and returns their names.
+func (self *SpellChecker) Finish() []string {
 	// The current value of self.changed could be wrong as it is not updated
 	// by undo actions but we want an accurate value here.
 	self.changed = false
@@ -317,25 +471,64 @@ func (self *SpellChecker) Finish() {
 		}
 	}
 	if self.discardAll || !self.changed {
-		return
+		return nil
+	}
+	return writeFiles(self.files, self.doBackup)
+}
+
+// SaveReplacements appends the "Replace all" decisions that are not saved yet
+// to the saved replacements file.
+func (self *SpellChecker) SaveReplacements(filename string) error {
+	if self.discardAll {
+		return nil
+	}
+	lines := []string{}
+	for from, to := range self.replacements {
+		if saved := self.saved.Lookup(from); !saved.IsSome() || saved.Unwrap() != to {
+			lines = append(lines, fmt.Sprintf("%s %s", from, to))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Strings(lines)
+	return appendFileLines(filename, lines)
+}
+
+// SaveChoices saves how often words were chosen as replacements, so they keep
+// ranking higher in later runs.
+func (self *SpellChecker) SaveChoices(filename string) error {
+	if self.discardAll {
+		return nil
 	}
+	return self.ranker.save(filename)
+}
+
+// writeFiles writes the changed files and returns their names. If doBackup is
+// set the original lines are written to the backup file.
+func writeFiles(files []FileContext, doBackup bool) []string {
 	backup := Backup{}
-	if self.doBackup {
+	if doBackup {
 		if err := backup.Create(); err != nil {
 			Fatal("could not created backup: %s", err)
 		}
 	}
-	for _, file := range self.files {
+	written := []string{}
+	for _, file := range files {
 		if !file.IsChanged() {
 			continue
 		}
 		if err := file.Write(); err != nil {
 			log.Printf("%s: could not write %s: %s\n", InvocationName, file.sf.Name(), err)
-		} else if self.doBackup {
+			continue
+		}
+		written = append(written, file.sf.Name())
+		if doBackup {
 			file.AddToBackup(&backup)
 		}
 	}
-	if self.doBackup {
+	if doBackup {
 		backup.Write()
 	}
+	return written
 }
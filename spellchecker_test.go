@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+)
+
+func TestSavedReplacements(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.General.SavedReplacements = filepath.Join(t.TempDir(), "saved")
+	empty := NewReplacements(true)
+	checker := SpellChecker{
+		replacements: map[string]Replacement{
+			"teh":     NewReplacement("=the", "Teh"),
+			"recieve": NewReplacement("Receive", "Recieve"),
+			"ios":     NewReplacement("iOS", "ios"),
+		},
+		saved: &empty,
+	}
+	if err := checker.SaveReplacements(cfg.General.SavedReplacements); err != nil {
+		t.Fatal(err)
+	}
+	saved := loadSavedReplacements(&cfg)
+	cases := []struct {
+		word        string
+		replacement string
+	}{
+		{"Teh", "the"},
+		{"TEH", "the"},
+		{"Recieve", "Receive"},
+		{"RECIEVE", "RECEIVE"},
+		{"IOS", "iOS"},
+	}
+	for _, c := range cases {
+		if got := saved.Get(c.word); got != Some(c.replacement) {
+			t.Errorf("%s: got %v, expected %s", c.word, got, c.replacement)
+		}
+	}
+	before, _ := os.ReadFile(cfg.General.SavedReplacements)
+	checker.saved = &saved
+	if err := checker.SaveReplacements(cfg.General.SavedReplacements); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(cfg.General.SavedReplacements); string(after) != string(before) {
+		t.Errorf("saved again:\n%s", after)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+)
+
+// hunkHeader matches the header of a hunk in a unified diff and captures the
+// range of the new file.
+var hunkHeader = regexp.MustCompile(`(?m)^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// stagedFile is the content of a file in the git index.
+type stagedFile struct {
+	content string
+	// The 0-based numbers of the lines added or changed in the staged diff.
+	changedLines map[int]bool
+	// Whether the working tree file has the same content, only then fixes can
+	// be written to both.
+	matchesWorkTree bool
+}
+
+// git runs a git command in the given directory and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && stderr.Len() != 0 {
+		return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return out, err
+}
+
+// changedLines returns the lines of the new file that are changed in a diff
+// with zero context lines.
+func changedLines(diff string) map[int]bool {
+	lines := map[int]bool{}
+	for _, m := range hunkHeader.FindAllStringSubmatch(diff, -1) {
+		start, _ := strconv.Atoi(m[1])
+		count := 1
+		if len(m[2]) != 0 {
+			count, _ = strconv.Atoi(m[2])
+		}
+		for i := 0; i < count; i++ {
+			lines[start-1+i] = true
+		}
+	}
+	return lines
+}
+
+// getStagedFiles returns the files that are added or modified in the git
+// index, keyed by their path relative to the current directory. Only files
+// matching the globs are included, if any are given.
+func getStagedFiles(globs []string) (map[string]stagedFile, error) {
+	out, err := git(".", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(out))
+	out, err = git(root, "diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]stagedFile{}
+	for _, name := range strings.Split(string(out), "\x00") {
+		if len(name) == 0 {
+			continue
+		}
+		filename := relPath(filepath.Join(root, name))
+		if !matchGlobs(globs, filename) {
+			continue
+		}
+		content, err := git(root, "show", ":"+name)
+		if err != nil {
+			return nil, err
+		}
+		diff, err := git(root, "diff", "--cached", "-U0", "--no-color", "--no-ext-diff", "--", name)
+		if err != nil {
+			return nil, err
+		}
+		workTree, err := os.ReadFile(filename)
+		files[filename] = stagedFile{
+			content:         string(content),
+			changedLines:    changedLines(string(diff)),
+			matchesWorkTree: err == nil && bytes.Equal(workTree, content),
+		}
+	}
+	return files, nil
+}
+
+// stageFiles adds the given files to the git index.
+func stageFiles(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	_, err := git(".", append([]string{"add", "--"}, names...)...)
+	return err
+}
+
+// stagedFixable waits for all files and returns them in a new channel if
+// their fixes can be written to both the working tree and the index. If they
+// can't the misspelled words are reported instead.
+func stagedFixable(
+	sourceFiles chan sf.SourceFile, staged map[string]stagedFile,
+) (chan sf.SourceFile, bool) {
+	all := []sf.SourceFile{}
+	fixable := true
+	for source := range sourceFiles {
+		all = append(all, source)
+		fixable = fixable && staged[source.Name()].matchesWorkTree
+	}
+	if !fixable {
+		log.Printf("%s: files have unstaged changes, not fixing staged files\n", InvocationName)
+		for i := range all {
+			reportWords(os.Stdout, &all[i])
+		}
+		return nil, false
+	}
+	out := make(chan sf.SourceFile, len(all))
+	for _, source := range all {
+		out <- source
+	}
+	close(out)
+	return out, true
+}
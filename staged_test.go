@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedLines(t *testing.T) {
+	diff := `diff --git a/x.go b/x.go
+index 1111111..2222222 100644
+--- a/x.go
++++ b/x.go
+@@ -1 +1 @@
+-// old
++// new
+@@ -5,0 +6,2 @@ func x() {
++// one
++// two
+@@ -10,3 +11,0 @@
+-a
+-b
+-c
+`
+	expected := map[int]bool{0: true, 5: true, 6: true}
+	if lines := changedLines(diff); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("got %v, expected %v", lines, expected)
+	}
+}
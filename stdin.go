@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/parser"
+	sf "github.com/JaMo42/spellcheck_comments/source_file"
+	"github.com/JaMo42/spellcheck_comments/tui"
+)
+
+// stdinName is the file name used for the buffer read from standard input.
+const stdinName = "stdin"
+
+// parseStdin parses the buffer read from standard input with the style for the
+// given language.
+func parseStdin(
+	input []byte,
+	language string,
+	cfg *Config,
+	dictionaries Dictionaries,
+	ignoreList *IgnoreList,
+	counts *WordCounts,
+) sf.SourceFile {
+	styleName := cfg.LanguageStyle(strings.TrimPrefix(language, "."))
+	if !styleName.IsSome() {
+		Fatal("no comment style for language: %s", language)
+	}
+	style := cfg.Styles[styleName.Unwrap()]
+	speller, _ := dictionaries.Select(cfg.FileDictionaries(stdinName, styleName.Unwrap()))
+	if cfg.General.LearnIdentifiers && !style.Notebook {
+		vocabulary := NewVocabulary()
+		parser.CollectIdentifiers(string(input), style, cfg, vocabulary)
+		speller.SetVocabulary(vocabulary)
+	}
+	if style.Notebook {
+		source, ok := parseNotebook(stdinName, input, cfg, speller, ignoreList, counts)
+		if !ok {
+			os.Exit(1)
+		}
+		return source
+	}
+	// The highlighters can only read files.
+	return parser.Parse(stdinName, string(input), style, speller, cfg, ignoreList, counts, true)
+}
+
+// runStdin checks a buffer read from standard input and writes the corrected
+// buffer to standard output. The interface uses the terminal directly so it
+// works while both are redirected. In check mode the buffer is written
+// unchanged and the misspelled words are reported to standard error, in fix
+// mode only the automatic replacements are applied.
+func runStdin(
+	options *Options,
+	cfg *Config,
+	ignoreList *IgnoreList,
+	replacements *Replacements,
+	saved *Replacements,
+) int {
+	if len(options.lang) == 0 {
+		Fatal("-stdin requires -lang")
+	}
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		Fatal("could not read standard input: %s", err)
+	}
+
+	dictionaries, err := NewDictionaries(cfg)
+	if err != nil {
+		Fatal("could not create speller: %s", err.Error())
+	}
+	defer dictionaries.Delete()
+
+	counts := NewWordCounts()
+	source := parseStdin(input, options.lang, cfg, dictionaries, ignoreList, counts)
+	if source.Ok() {
+		os.Stdout.Write(input)
+		return 0
+	}
+	if options.check {
+		os.Stdout.Write(input)
+		reportWords(os.Stderr, &source)
+		return 1
+	}
+
+	var file *FileContext
+	var checker SpellChecker
+	if options.fix {
+		fixed := NewFileContext(source)
+		fixed.ApplyReplacements(replacements)
+		file = &fixed
+	} else {
+		scr := tui.Init(cfg)
+		checker = NewSpellChecker(scr, cfg, ignoreList, counts, replacements, saved)
+		loadSavedChoices(&checker, cfg)
+		checker.AddFile(source)
+		checker.Run()
+		tui.Quit(scr)
+		if checker.discardAll {
+			os.Stdout.Write(input)
+			return 1
+		}
+		file = &checker.files[0]
+		saveReplacements(&checker, cfg)
+		saveChoices(&checker, cfg)
+	}
+	output, err := file.Source().Bytes()
+	if err != nil {
+		log.Printf("%s: %s\n", InvocationName, err)
+		os.Stdout.Write(input)
+		return 1
+	}
+	os.Stdout.Write(output)
+	if !options.fix && len(options.saveIgnoreList.s) != 0 {
+		saveIgnoreList(options.saveIgnoreList.s, &checker, os.Stderr)
+	}
+	return 0
+}
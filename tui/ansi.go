@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/JaMo42/spellcheck_comments/util"
+	"github.com/gdamore/tcell/v2"
+)
+
+const esc = '\x1b'
+
+// sgrParameter is a single parameter of a SGR sequence, the first value is
+// the parameter itself and any further values are colon separated
+// sub-parameters. A value of -1 means the value was omitted.
+type sgrParameter []int
+
+func parseSgrParameters(params string) []sgrParameter {
+	result := []sgrParameter{}
+	for _, param := range strings.Split(params, ";") {
+		parts := strings.Split(param, ":")
+		values := make(sgrParameter, len(parts))
+		for i, part := range parts {
+			if len(part) == 0 {
+				values[i] = -1
+			} else if value, err := strconv.Atoi(part); err == nil {
+				values[i] = value
+			} else {
+				values[i] = -1
+			}
+		}
+		result = append(result, values)
+	}
+	return result
+}
+
+// value returns the value at the given index or the default value if it does
+// not exist or was omitted.
+func (self sgrParameter) value(index, def int) int {
+	if index >= len(self) || self[index] < 0 {
+		return def
+	}
+	return self[index]
+}
+
+func clampColorComponent(c int) int32 {
+	if c < 0 {
+		return 0
+	} else if c > 255 {
+		return 255
+	}
+	return int32(c)
+}
+
+// extendedColor parses the color of a 38, 48, or 58 parameter. Returns the
+// color, whether it's valid, and the remaining parameters.
+func extendedColor(param sgrParameter, rest []sgrParameter) (tcell.Color, bool, []sgrParameter) {
+	// Colon separated form, the whole color is inside a single parameter.
+	if len(param) > 1 {
+		switch param.value(1, -1) {
+		case 5:
+			return tcell.PaletteColor(param.value(2, 0) & 0xff), true, rest
+		case 2:
+			// The standard form contains a color space id before the
+			// components (38:2:id:r:g:b) but it is commonly left out.
+			offset := 2
+			if len(param) >= 6 {
+				offset = 3
+			}
+			return tcell.NewRGBColor(
+				clampColorComponent(param.value(offset, 0)),
+				clampColorComponent(param.value(offset+1, 0)),
+				clampColorComponent(param.value(offset+2, 0)),
+			), true, rest
+		}
+		return tcell.ColorDefault, false, rest
+	}
+	// Semicolon separated form, the color consumes the following parameters.
+	next := func() int {
+		if len(rest) == 0 {
+			return 0
+		}
+		value := rest[0].value(0, 0)
+		rest = rest[1:]
+		return value
+	}
+	switch next() {
+	case 5:
+		return tcell.PaletteColor(next() & 0xff), true, rest
+	case 2:
+		red := clampColorComponent(next())
+		green := clampColorComponent(next())
+		blue := clampColorComponent(next())
+		return tcell.NewRGBColor(red, green, blue), true, rest
+	}
+	return tcell.ColorDefault, false, rest
+}
+
+func italic(style tcell.Style, on bool) tcell.Style {
+	if italicAsUnderline {
+		return style.Underline(on)
+	}
+	return style.Italic(on)
+}
+
+// Ansi2Style converts a SGR ansi escape sequence to a tcell Style. Any other
+// sequence gives the default style.
+func Ansi2Style(sequence string) (style tcell.Style) {
+	runes := []rune(sequence)
+	if len(runes) == 0 || runes[0] != esc {
+		return tcell.StyleDefault
+	}
+	length, kind := util.ScanEscape(runes)
+	if kind != util.EscapeSGR || length != len(runes) {
+		return tcell.StyleDefault
+	}
+	params := parseSgrParameters(string(runes[2 : length-1]))
+	var param sgrParameter
+	for len(params) > 0 {
+		param, params = params[0], params[1:]
+		// An empty parameter means 0 (reset).
+		code := param.value(0, 0)
+		switch {
+		case code == 0:
+			style = tcell.StyleDefault
+		case code == 1:
+			style = style.Bold(true)
+		case code == 2:
+			style = style.Dim(true)
+		case code == 3:
+			style = italic(style, true)
+		case code == 4:
+			// 4:0 disables underlining, any other style (single, double,
+			// curly, ...) is displayed as normal underline.
+			style = style.Underline(param.value(1, 1) != 0)
+		case code == 5, code == 6:
+			style = style.Blink(true)
+		case code == 7:
+			style = style.Reverse(true)
+		case code == 9:
+			style = style.StrikeThrough(true)
+		case code == 21:
+			style = style.Underline(true)
+		case code == 22:
+			style = style.Bold(false).Dim(false)
+		case code == 23:
+			style = italic(style, false)
+		case code == 24:
+			style = style.Underline(false)
+		case code == 25:
+			style = style.Blink(false)
+		case code == 27:
+			style = style.Reverse(false)
+		case code == 29:
+			style = style.StrikeThrough(false)
+		case code >= 30 && code <= 37:
+			style = style.Foreground(tcell.PaletteColor(code - 30))
+		case code == 38:
+			var color tcell.Color
+			var ok bool
+			color, ok, params = extendedColor(param, params)
+			if ok {
+				style = style.Foreground(color)
+			}
+		case code == 39:
+			style = style.Foreground(tcell.ColorDefault)
+		case code >= 40 && code <= 47:
+			style = style.Background(tcell.PaletteColor(code - 40))
+		case code == 48:
+			var color tcell.Color
+			var ok bool
+			color, ok, params = extendedColor(param, params)
+			if ok {
+				style = style.Background(color)
+			}
+		case code == 49:
+			style = style.Background(tcell.ColorDefault)
+		case code == 58:
+			// Underline color is not supported by tcell, but its arguments
+			// still need to be consumed.
+			_, _, params = extendedColor(param, params)
+		case code >= 90 && code <= 97:
+			style = style.Foreground(tcell.PaletteColor(code - 90 + 8))
+		case code >= 100 && code <= 107:
+			style = style.Background(tcell.PaletteColor(code - 100 + 8))
+		}
+		// Everything else (fonts, conceal, overline, frames, ideogram
+		// attributes, ...) has no equivalent and is ignored.
+	}
+	return style
+}
@@ -0,0 +1,35 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestAnsi2Style(t *testing.T) {
+	cases := []struct {
+		sequence string
+		style    tcell.Style
+	}{
+		{"", tcell.StyleDefault},
+		{"\x1b[m", tcell.StyleDefault},
+		{"\x1b[1;3;4m", tcell.StyleDefault.Bold(true).Italic(true).Underline(true)},
+		{"\x1b[4:3m", tcell.StyleDefault.Underline(true)},
+		{"\x1b[4:0m", tcell.StyleDefault},
+		{"\x1b[9;5m", tcell.StyleDefault.StrikeThrough(true).Blink(true)},
+		{"\x1b[31;102m", tcell.StyleDefault.Foreground(tcell.ColorMaroon).Background(tcell.ColorLime)},
+		{"\x1b[38;5;213m", tcell.StyleDefault.Foreground(tcell.PaletteColor(213))},
+		{"\x1b[38:5:213m", tcell.StyleDefault.Foreground(tcell.PaletteColor(213))},
+		{"\x1b[48;2;1;2;3m", tcell.StyleDefault.Background(tcell.NewRGBColor(1, 2, 3))},
+		{"\x1b[48:2::1:2:3m", tcell.StyleDefault.Background(tcell.NewRGBColor(1, 2, 3))},
+		{"\x1b[48:2:1:2:3m", tcell.StyleDefault.Background(tcell.NewRGBColor(1, 2, 3))},
+		{"\x1b[58;2;1;2;3;1m", tcell.StyleDefault.Bold(true)},
+		{"\x1b[1;22m", tcell.StyleDefault},
+		{"\x1b[?1m", tcell.StyleDefault},
+	}
+	for _, c := range cases {
+		if style := Ansi2Style(c.sequence); style != c.style {
+			t.Errorf("%q: wrong style", c.sequence)
+		}
+	}
+}
@@ -5,25 +5,16 @@ import (
 	"fmt"
 	"log"
 	"reflect"
-	"strconv"
-	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-runewidth"
 
 	. "github.com/JaMo42/spellcheck_comments/common"
-	"github.com/JaMo42/spellcheck_comments/util"
 )
 
 var (
 	boxStyle          BoxStyle
 	italicAsUnderline bool
-	palettes          = []PaletteSpec{
-		{false, 30, 37, 0},
-		{true, 40, 47, 0},
-		{false, 90, 97, 8},
-		{true, 100, 107, 8},
-	}
 	// FIXME: this is ugly and feels out of place here
 	Colors = struct {
 		Comment,
@@ -43,12 +34,6 @@ var (
 	Alignment = struct{ Begin, Center, End, Fill int }{0, 1, 2, 3}
 )
 
-type PaletteSpec struct {
-	isBackground  bool
-	first, last   int
-	paletteOffset int
-}
-
 type BoxStyle struct {
 	Vertical       rune
 	Horizontal     rune
@@ -205,109 +190,6 @@ func FillRect(scr tcell.Screen, x, y, width, height int, char rune, style tcell.
 	}
 }
 
-func getColor(tok int, tokens []int, style tcell.Style) (tcell.Style, []int) {
-	isBackground := false
-	color := tcell.ColorDefault
-	for _, palette := range palettes {
-		if tok >= palette.first && tok <= palette.last {
-			isBackground = palette.isBackground
-			color = tcell.PaletteColor(palette.paletteOffset + tok - palette.first)
-			goto done
-		}
-	}
-	if tok == 38 || tok == 48 {
-		isBackground = tok == 48
-		tok, tokens = util.PopFrontOrDefault(tokens)
-		if tok == 5 {
-			var index int
-			index, tokens = util.PopFrontOrDefault(tokens)
-			color = tcell.PaletteColor(index)
-		} else if tok == 2 {
-			var red, green, blue int
-			red, tokens = util.PopFrontOrDefault(tokens)
-			green, tokens = util.PopFrontOrDefault(tokens)
-			blue, tokens = util.PopFrontOrDefault(tokens)
-			color = tcell.NewRGBColor(int32(red), int32(green), int32(blue))
-		}
-		// silently ignore invalid color type
-	} else if tok == 39 || tok == 49 {
-		isBackground = tok == 49
-		// color is already the default
-	}
-done:
-	if isBackground {
-		style = style.Background(color)
-	} else {
-		style = style.Foreground(color)
-	}
-	return style, tokens
-}
-
-// Ansi2Style converts a SGR ansi escape sequence to a tcell Style.
-func Ansi2Style(sequence string) (style tcell.Style) {
-	if len(sequence) == 0 {
-		return tcell.StyleDefault
-	}
-	// Out lexer only understands sequences ending with 'm' so we don't need
-	// to verify it's a SGR sequence.
-	tokens := util.Map(
-		strings.Split(sequence[2:len(sequence)-1], ";"),
-		func(s string) int {
-			i, _ := strconv.Atoi(s)
-			// We can ignore any errors here, this will just give us 0 (reset)
-			// for empty and invalid tokens which is the best way to handle
-			// them anyways.
-			return i
-		},
-	)
-	var tok int
-	for len(tokens) > 0 {
-		tok, tokens = util.PopFront(tokens)
-		switch tok {
-		case 0:
-			style = tcell.StyleDefault
-		case 1:
-			style = style.Bold(true)
-		case 2:
-			style = style.Dim(true)
-		case 3:
-			if italicAsUnderline {
-				style = style.Underline(true)
-			} else {
-				style = style.Italic(true)
-			}
-		case 4:
-			style = style.Underline(true)
-		// blinking is left out
-		case 7:
-			style = style.Reverse(true)
-
-		case 22:
-			style = style.Bold(false).Dim(false)
-		case 23:
-			if italicAsUnderline {
-				style = style.Underline(false)
-			} else {
-				style = style.Italic(false)
-			}
-		case 24:
-			style = style.Underline(false)
-		case 27:
-			style = style.Reverse(false)
-
-		case
-			30, 31, 32, 33, 34, 35, 36, 37,
-			38,
-			39,
-			40, 41, 42, 43, 44, 45, 46, 47,
-			48,
-			49:
-			style, tokens = getColor(tok, tokens, style)
-		}
-	}
-	return style
-}
-
 func alignAxis(avail, use, alignment int) (int, int) {
 	switch alignment {
 	case Alignment.Begin:
@@ -2,29 +2,55 @@ package tui
 
 import (
 	"time"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 
 	"github.com/JaMo42/spellcheck_comments/util"
 )
 
+// GetLineState is the state of a single line text input with a cursor.
 type GetLineState struct {
 	history   []string
 	lastEvent time.Time
+	// The position of the cursor in runes.
+	cursor int
 }
 
-func (self *GetLineState) WriteRune(char rune) {
-	s := self.String() + string(char)
+// SetString sets the text and puts the cursor at its end.
+func (self *GetLineState) SetString(s string) {
 	self.history = append(self.history, s)
+	self.cursor = utf8.RuneCountInString(s)
+}
+
+// setRunes adds the text to the history and moves the cursor.
+func (self *GetLineState) setRunes(runes []rune, cursor int) {
+	self.history = append(self.history, string(runes))
+	self.cursor = cursor
+}
+
+func (self *GetLineState) WriteRune(char rune) {
+	runes := []rune(self.String())
+	runes = append(runes[:self.cursor], append([]rune{char}, runes[self.cursor:]...)...)
+	self.setRunes(runes, self.cursor+1)
 }
 
 func (self *GetLineState) Backspace() bool {
-	s := self.String()
-	if len(s) == 0 || len(self.history) == 0 {
+	runes := []rune(self.String())
+	if self.cursor == 0 || len(self.history) == 0 {
 		return false
 	}
-	s = s[:len(s)-1]
-	self.history = append(self.history, s)
+	self.setRunes(append(runes[:self.cursor-1], runes[self.cursor:]...), self.cursor-1)
+	return true
+}
+
+// Delete deletes the character at the cursor.
+func (self *GetLineState) Delete() bool {
+	runes := []rune(self.String())
+	if self.cursor >= len(runes) {
+		return false
+	}
+	self.setRunes(append(runes[:self.cursor], runes[self.cursor+1:]...), self.cursor)
 	return true
 }
 
@@ -32,7 +58,7 @@ func (self *GetLineState) Clear() bool {
 	if len(self.String()) == 0 || len(self.history) == 0 {
 		return false
 	}
-	self.history = append(self.history, "")
+	self.setRunes(nil, 0)
 	return true
 }
 
@@ -41,9 +67,19 @@ func (self *GetLineState) Undo() bool {
 		return false
 	}
 	self.history = self.history[:len(self.history)-1]
+	self.cursor = util.Min(self.cursor, utf8.RuneCountInString(self.String()))
 	return true
 }
 
+// MoveCursor moves the cursor to the given position, clamped to the text.
+// Returns true if it moved.
+func (self *GetLineState) MoveCursor(position int) bool {
+	position = util.Clamp(position, 0, utf8.RuneCountInString(self.String()))
+	moved := position != self.cursor
+	self.cursor = position
+	return moved
+}
+
 func (self *GetLineState) String() string {
 	if len(self.history) == 0 {
 		return ""
@@ -51,14 +87,20 @@ func (self *GetLineState) String() string {
 	return *util.Back(self.history)
 }
 
-// Display returns the string to display the state and a character to highlight.
-func (self *GetLineState) Display(width int) (string, int) {
-	s := self.String()
-	if len(s) >= width {
-		skip := len(s) - width + 2
-		return "<" + s[skip:], 0
+// Display returns the string to display the state, a character to highlight,
+// and the position of the cursor within the string. If the text is too long
+// it's scrolled so the cursor is visible.
+func (self *GetLineState) Display(width int) (string, int, int) {
+	runes := []rune(self.String())
+	if len(runes) < width {
+		return string(runes), -1, self.cursor
+	}
+	start := util.Max(self.cursor-width+2, 0)
+	end := util.Min(start+width-1, len(runes))
+	if start == 0 {
+		return string(runes[:end]), -1, self.cursor
 	}
-	return s, -1
+	return "<" + string(runes[start+1:end]), 0, self.cursor - start
 }
 
 // Event processes a key event, returning true if a redraw is necessary.
@@ -74,6 +116,21 @@ func (self *GetLineState) Event(event *tcell.EventKey) bool {
 			return self.Backspace()
 		}
 
+	case tcell.KeyDelete:
+		return self.Delete()
+
+	case tcell.KeyLeft:
+		return self.MoveCursor(self.cursor - 1)
+
+	case tcell.KeyRight:
+		return self.MoveCursor(self.cursor + 1)
+
+	case tcell.KeyHome, tcell.KeyCtrlA:
+		return self.MoveCursor(0)
+
+	case tcell.KeyEnd, tcell.KeyCtrlE:
+		return self.MoveCursor(utf8.RuneCountInString(self.String()))
+
 	case tcell.KeyCtrlZ:
 		return self.Undo()
 
@@ -5,6 +5,7 @@ import (
 	"github.com/mattn/go-runewidth"
 
 	. "github.com/JaMo42/spellcheck_comments/common"
+	"github.com/JaMo42/spellcheck_comments/util"
 )
 
 var (
@@ -24,6 +25,7 @@ type inputBox struct {
 	suggestions        *ListView
 	inputFocused       bool
 	suggestionCount    int
+	minWidth           int
 }
 
 func InputBox(
@@ -54,6 +56,19 @@ func InputBox(
 	return ib.Run()
 }
 
+// EditBox shows an input box for editing the given text. The box is widened
+// to fit the text if possible.
+func EditBox(scr tcell.Screen, caption, text string) Optional[string] {
+	ib := inputBox{
+		scr:          scr,
+		caption:      caption,
+		inputFocused: true,
+		minWidth:     runewidth.StringWidth(text) + 5,
+	}
+	ib.state.SetString(text)
+	return ib.Run()
+}
+
 func (self *inputBox) Run() Optional[string] {
 	self.Layout()
 	self.Redraw()
@@ -162,7 +177,7 @@ func (self *inputBox) Layout() {
 	if self.suggestionProvider != nil {
 		contentHeight += 5
 	}
-	width := screenWidth / 3
+	width := util.Min(util.Max(screenWidth/3, self.minWidth), screenWidth-4)
 	height := contentHeight + 2
 	x := (screenWidth - width) / 2
 	y := (screenHeight - height) / 2
@@ -209,13 +224,14 @@ func (self *inputBox) UpdateText() {
 		outlineStyle = tcell.StyleDefault.Foreground(tcell.PaletteColor(243))
 	}
 	Box(self.scr, x-1, y-1, width+2, 3, outlineStyle)
-	text, highlight := self.state.Display(width)
+	text, highlight, cursor := self.state.Display(width)
 	if len(text) != 0 {
 		HLine(self.scr, x, y, width, ' ', tcell.StyleDefault)
 		highlightStyle := tcell.StyleDefault.Foreground(tcell.ColorBlue)
-		cx := TextWithHighlight(
+		TextWithHighlight(
 			self.scr, x, y, text, highlight, tcell.StyleDefault, highlightStyle,
 		)
+		cx := x + runewidth.StringWidth(string([]rune(text)[:cursor]))
 		self.scr.ShowCursor(cx, y)
 	} else {
 		Text(self.scr, x, y, self.placeholder, tcell.StyleDefault.Dim(true))
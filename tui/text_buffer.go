@@ -72,6 +72,10 @@ func (self *SliceIndex) Line() int {
 	return self.line
 }
 
+func (self *SliceIndex) Slice() int {
+	return self.slice
+}
+
 // IsSameOrAfter returns true if this slice is equal to or after the given slice.
 func (self *SliceIndex) IsSameOrAfter(other SliceIndex) bool {
 	return self.line > other.line || (self.line == other.line && self.slice >= other.slice)
@@ -120,18 +124,21 @@ func (self *TextBuffer) AddSlice(slice string) SliceIndex {
 	return NewSliceIndex(len(self.lines)-1, sliceIdx)
 }
 
-// addTabs adds a slice consisting of only tabs to the text buffer.
-func (self *TextBuffer) addTabs(count int) {
-	line := util.Back(self.lines)
-	startingOffset := line.width
-	var width int
+// tabsWidth returns the width of count tabs starting at the given offset.
+func (self *TextBuffer) tabsWidth(startingOffset, count int) int {
 	// If we're not a multiple of the tab size we need to shorten the shift
 	// width of the first tab.
 	if startingOffset%self.tabSize == 0 {
-		width = count * self.tabSize
+		return count * self.tabSize
 	} else {
-		width = startingOffset%self.tabSize + (count-1)*self.tabSize
+		return startingOffset%self.tabSize + (count-1)*self.tabSize
 	}
+}
+
+// addTabs adds a slice consisting of only tabs to the text buffer.
+func (self *TextBuffer) addTabs(count int) {
+	line := util.Back(self.lines)
+	width := self.tabsWidth(line.width, count)
 	line.addSliceWithWidth(strings.Repeat("\t", count), width, self.style)
 	self.capacity += count
 }
@@ -169,6 +176,11 @@ func (self *TextBuffer) RemoveLastLineIfEmpty() {
 	}
 }
 
+// LineCount returns the number of lines.
+func (self *TextBuffer) LineCount() int {
+	return len(self.lines)
+}
+
 // NextIndex returns the index of the next slice being added, assuming no
 // newline is added before.
 func (self *TextBuffer) NextIndex() SliceIndex {
@@ -193,6 +205,46 @@ func (self *TextBuffer) SetSliceText(idx SliceIndex, text string) {
 	self.lines[idx.line].computeOffsets()
 }
 
+// ReplaceSlices replaces the slices first to last (inclusive) of a line with
+// slices of the given texts, which get the style of the first replaced slice.
+// Texts consisting only of tabs are sized like tabs.
+func (self *TextBuffer) ReplaceSlices(line, first, last int, texts []string) {
+	old := self.lines[line].slices
+	style := old[first].style
+	slices := append([]TextSlice{}, old[:first]...)
+	offset := old[first].offset
+	for _, text := range texts {
+		width := runewidth.StringWidth(text)
+		if len(strings.Trim(text, "\t")) == 0 && len(text) > 0 {
+			width = self.tabsWidth(offset, len(text))
+		}
+		slices = append(slices, TextSlice{text, style, offset, width})
+		offset += width
+	}
+	slices = append(slices, old[last+1:]...)
+	self.SetLineSlices(line, slices)
+}
+
+// LineSlices returns a copy of the slices of a line.
+func (self *TextBuffer) LineSlices(line int) []TextSlice {
+	return append([]TextSlice{}, self.lines[line].slices...)
+}
+
+// SetLineSlices sets the slices of a line, like the ones from LineSlices.
+func (self *TextBuffer) SetLineSlices(line int, slices []TextSlice) {
+	for _, slice := range self.lines[line].slices {
+		self.capacity -= len(slice.text)
+	}
+	target := &self.lines[line]
+	target.slices = slices
+	target.computeOffsets()
+	target.width = 0
+	for _, slice := range slices {
+		self.capacity += len(slice.text)
+		target.width += slice.width
+	}
+}
+
 func (self *TextBuffer) PrintLineAt(scr tcell.Screen, line, x, y int) {
 	col := x
 	for _, slice := range self.lines[line].slices {
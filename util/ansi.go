@@ -0,0 +1,99 @@
+package util
+
+const (
+	esc = '\x1b'
+	bel = '\x07'
+)
+
+// EscapeKind identifies the type of an escape sequence.
+type EscapeKind int
+
+const (
+	// EscapeOther is any complete sequence that we do not interpret.
+	EscapeOther EscapeKind = iota
+	// EscapeSGR is a CSI sequence ending in `m` (Select Graphic Rendition).
+	EscapeSGR
+	// EscapeIncomplete is a sequence that was interrupted or hit the end of
+	// the input before its final byte.
+	EscapeIncomplete
+)
+
+func isParameterByte(c rune) bool {
+	return c >= 0x30 && c <= 0x3f
+}
+
+func isIntermediateByte(c rune) bool {
+	return c >= 0x20 && c <= 0x2f
+}
+
+func isFinalByte(c rune) bool {
+	return c >= 0x40 && c <= 0x7e
+}
+
+// scanControlString returns the length of a control string (OSC, DCS, SOS,
+// PM, and APC) starting at source[start]. Control strings are terminated by
+// ST (`ESC \`), we also accept BEL as terminator like most terminals do.
+func scanControlString(source []rune, start int) (int, EscapeKind) {
+	for i := start; i < len(source); i++ {
+		switch source[i] {
+		case bel:
+			return i + 1, EscapeOther
+		case esc:
+			if i+1 < len(source) && source[i+1] == '\\' {
+				return i + 2, EscapeOther
+			}
+			// Another escape sequence starts, so this one was cut short.
+			return i, EscapeIncomplete
+		case 0:
+			return i, EscapeIncomplete
+		}
+	}
+	return len(source), EscapeIncomplete
+}
+
+// scanCSI returns the length of a control sequence whose parameter bytes
+// start at source[start].
+func scanCSI(source []rune, start int) (int, EscapeKind) {
+	i := start
+	private := i < len(source) && source[i] >= '<' && source[i] <= '?'
+	for i < len(source) && isParameterByte(source[i]) {
+		i++
+	}
+	intermediates := i
+	for i < len(source) && isIntermediateByte(source[i]) {
+		i++
+	}
+	if i == len(source) || !isFinalByte(source[i]) {
+		return i, EscapeIncomplete
+	}
+	if source[i] == 'm' && !private && intermediates == i {
+		return i + 1, EscapeSGR
+	}
+	return i + 1, EscapeOther
+}
+
+// ScanEscape scans the 7-bit ECMA-48 escape sequence at the beginning of
+// source, which must start with an ESC character. It returns the length of
+// the sequence in runes and what kind of sequence it is. Incomplete
+// sequences end right before the character that interrupted them.
+func ScanEscape(source []rune) (int, EscapeKind) {
+	if len(source) < 2 {
+		return len(source), EscapeIncomplete
+	}
+	switch source[1] {
+	case '[':
+		return scanCSI(source, 2)
+	case ']', 'P', 'X', '^', '_':
+		return scanControlString(source, 2)
+	}
+	// nF sequences have any number of intermediate bytes, all other escape
+	// sequences are just ESC followed by the final byte.
+	i := 1
+	for i < len(source) && isIntermediateByte(source[i]) {
+		i++
+	}
+	if i < len(source) && source[i] >= 0x30 && source[i] <= 0x7e {
+		return i + 1, EscapeOther
+	}
+	return i, EscapeIncomplete
+}
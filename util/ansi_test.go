@@ -0,0 +1,32 @@
+package util
+
+import "testing"
+
+func TestScanEscape(t *testing.T) {
+	cases := []struct {
+		sequence string
+		length   int
+		kind     EscapeKind
+	}{
+		{"\x1b[m", 3, EscapeSGR},
+		{"\x1b[1;38;2;1;2;3mtext", 15, EscapeSGR},
+		{"\x1b[?25h", 6, EscapeOther},
+		{"\x1b[>4;2m", 7, EscapeOther},
+		{"\x1b[2 q", 5, EscapeOther},
+		{"\x1b]8;;url\x1b\\", 10, EscapeOther},
+		{"\x1b]0;title\x07", 10, EscapeOther},
+		{"\x1b(B", 3, EscapeOther},
+		{"\x1b=", 2, EscapeOther},
+		{"\x1b[31\n", 4, EscapeIncomplete},
+		{"\x1b]8;;url", 8, EscapeIncomplete},
+	}
+	for _, c := range cases {
+		length, kind := ScanEscape([]rune(c.sequence))
+		if length != c.length || kind != c.kind {
+			t.Errorf(
+				"%q: got (%d, %d), expected (%d, %d)",
+				c.sequence, length, kind, c.length, c.kind,
+			)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"regexp/syntax"
+	"sort"
+	"unicode"
+)
+
+// maxRegexPrefixes limits how many prefixes we enumerate for alternations and
+// character classes.
+const maxRegexPrefixes = 256
+
+// regexPrefixes returns the fixed texts a match of the regex can begin with.
+// complete is whether a match is exactly one of them, so the prefixes of what
+// follows the regex can be appended.
+func regexPrefixes(re *syntax.Regexp) (prefixes []string, complete bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		prefixes = []string{""}
+		for _, c := range re.Rune {
+			variants := []rune{c}
+			if re.Flags&syntax.FoldCase != 0 {
+				for r := unicode.SimpleFold(c); r != c; r = unicode.SimpleFold(r) {
+					variants = append(variants, r)
+				}
+			}
+			if len(prefixes)*len(variants) > maxRegexPrefixes {
+				return prefixes, false
+			}
+			prefixes = appendRunes(prefixes, variants)
+		}
+		return prefixes, true
+	case syntax.OpCharClass:
+		var runes []rune
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if len(runes)+int(re.Rune[i+1]-re.Rune[i]) >= maxRegexPrefixes {
+				return []string{""}, false
+			}
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				runes = append(runes, r)
+			}
+		}
+		return appendRunes([]string{""}, runes), true
+	case syntax.OpCapture:
+		return regexPrefixes(re.Sub[0])
+	case syntax.OpPlus:
+		prefixes, _ = regexPrefixes(re.Sub[0])
+		return prefixes, false
+	case syntax.OpStar:
+		return []string{""}, false
+	case syntax.OpQuest:
+		prefixes, complete = regexPrefixes(re.Sub[0])
+		return append(prefixes, ""), complete
+	case syntax.OpConcat:
+		prefixes = []string{""}
+		for _, sub := range re.Sub {
+			subPrefixes, subComplete := regexPrefixes(sub)
+			if len(prefixes)*len(subPrefixes) > maxRegexPrefixes {
+				return prefixes, false
+			}
+			var joined []string
+			for _, prefix := range prefixes {
+				for _, subPrefix := range subPrefixes {
+					joined = append(joined, prefix+subPrefix)
+				}
+			}
+			prefixes = joined
+			if !subComplete {
+				return prefixes, false
+			}
+		}
+		return prefixes, true
+	case syntax.OpAlternate:
+		complete = true
+		for _, sub := range re.Sub {
+			subPrefixes, subComplete := regexPrefixes(sub)
+			prefixes = append(prefixes, subPrefixes...)
+			complete = complete && subComplete
+		}
+		if len(prefixes) > maxRegexPrefixes {
+			return []string{""}, false
+		}
+		return prefixes, complete
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary,
+		syntax.OpNoWordBoundary:
+		// These don't consume input so they don't change the prefixes.
+		return []string{""}, true
+	}
+	return []string{""}, false
+}
+
+// appendRunes returns each of the prefixes followed by each of the runes.
+func appendRunes(prefixes []string, runes []rune) []string {
+	result := make([]string, 0, len(prefixes)*len(runes))
+	for _, prefix := range prefixes {
+		for _, r := range runes {
+			result = append(result, prefix+string(r))
+		}
+	}
+	return result
+}
+
+// RegexPrefixes returns the fixed texts a match of the pattern begins with, so
+// the pattern only needs to be tried where one of them is found. Returns nil
+// if the pattern is invalid or can begin with any text.
+func RegexPrefixes(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	prefixes, _ := regexPrefixes(re.Simplify())
+	if Contains(prefixes, "") {
+		return nil
+	}
+	prefixes = Deduplicate(prefixes)
+	sort.Strings(prefixes)
+	return prefixes
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegexPrefixes(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		prefixes []string
+	}{
+		{`--\[(=*)\[`, []string{"--["}},
+		{`(?:u8|[uUL])?R"([^()\\\s]{0,16})\(`, []string{`LR"`, `R"`, `UR"`, `u8R"`, `uR"`}},
+		{`[bc]?r(#*)"`, []string{"br", "cr", "r"}},
+		{`(?i)b?r`, []string{"BR", "Br", "R", "bR", "br", "r"}},
+		{`<<[-~]?[ \t]*x`, []string{"<<", "<<-", "<<~"}},
+		{`#[ \t]*if\b`, []string{"#"}},
+		{`a*`, nil},
+		{`.x`, nil},
+		{`\S+`, nil},
+	}
+	for _, c := range cases {
+		if prefixes := RegexPrefixes(c.pattern); !reflect.DeepEqual(prefixes, c.prefixes) {
+			t.Errorf("%s: got %q, expected %q", c.pattern, prefixes, c.prefixes)
+		}
+	}
+}
@@ -3,6 +3,11 @@
 package util
 
 import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/mattn/go-runewidth"
 )
 
@@ -48,6 +53,11 @@ func StableFilter[T any](arr []T, pred func(T) bool) []T {
 	return filtered[:i]
 }
 
+// Copy returns a shallow copy of arr.
+func Copy[T any](arr []T) []T {
+	return append([]T{}, arr...)
+}
+
 // Map applies f to each value of arr.
 func Map[T any, U any](arr []T, f func(T) U) []U {
 	result := make([]U, len(arr))
@@ -103,17 +113,6 @@ func MaxElem(arr []int) (max int) {
 	return max
 }
 
-// String2Int creates an integer with the utf-8 representation of the string.
-// The last character is the lowest byte.
-func String2Int(s string) uint64 {
-	asInt := uint64(0)
-	for _, b := range []byte(s) {
-		asInt <<= 8
-		asInt |= uint64(b)
-	}
-	return asInt
-}
-
 // StrLen simply wraps a call to len(s). Unlike len this can be used as a
 // parameter to other functions.
 func StrLen(s string) int {
@@ -141,6 +140,14 @@ func Max(a, b int) int {
 	return b
 }
 
+// Abs returns the absolute value of an integer.
+func Abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 // Clamp clamps the value in the given inclusive range.
 func Clamp(x, lo, hi int) int {
 	return Min(Max(x, lo), hi)
@@ -168,3 +175,134 @@ func Deduplicate[T comparable](arr []T) []T {
 	}
 	return filtered
 }
+
+// MatchCase changes the case of word to match like: all upper case if like is
+// all upper case and at least two letters long, capitalized if like begins
+// with an upper case letter, and unchanged otherwise.
+func MatchCase(word, like string) string {
+	first, _ := utf8.DecodeRuneInString(like)
+	if !unicode.IsUpper(first) {
+		return word
+	}
+	if utf8.RuneCountInString(like) > 1 && strings.ToUpper(like) == like {
+		return strings.ToUpper(word)
+	}
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
+
+// BaseCase returns a replacement chosen for word in lower case if its case only
+// follows the case of the word, like Receive for Recieve, so FollowCase can
+// apply it to other occurrences. Otherwise it's returned unchanged.
+func BaseCase(replacement, word string) string {
+	lower := strings.ToLower(replacement)
+	if MatchCase(lower, word) == replacement {
+		return lower
+	}
+	return replacement
+}
+
+// FollowCase changes the case of a lower case replacement to match word, see
+// MatchCase. Replacements with their own case, like iPhone, are unchanged.
+func FollowCase(replacement, word string) string {
+	if strings.ToLower(replacement) != replacement {
+		return replacement
+	}
+	return MatchCase(replacement, word)
+}
+
+// EditDistance returns the Levenshtein distance between two strings, counted
+// in runes.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			next := Min(Min(row[j], row[j-1])+1, diagonal+cost)
+			diagonal, row[j] = row[j], next
+		}
+	}
+	return row[len(rb)]
+}
+
+// qwertyRows are the letter rows of a QWERTY keyboard.
+var qwertyRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// keyPositions maps letters to their row and column on a QWERTY keyboard.
+var keyPositions = func() map[rune][2]int {
+	positions := make(map[rune][2]int)
+	for row, letters := range qwertyRows {
+		for column, letter := range letters {
+			positions[letter] = [2]int{row, column}
+		}
+	}
+	return positions
+}()
+
+// adjacentKeys returns true if two letters are next to each other on a QWERTY
+// keyboard, including the neighbors in the rows above and below.
+func adjacentKeys(a, b rune) bool {
+	pa, ok := keyPositions[unicode.ToLower(a)]
+	if !ok {
+		return false
+	}
+	pb, ok := keyPositions[unicode.ToLower(b)]
+	if !ok {
+		return false
+	}
+	if pa[0] > pb[0] {
+		pa, pb = pb, pa
+	}
+	// Each row is shifted to the right of the row above it, so a key is
+	// between the keys in the same and the next column of the row above.
+	columns := pa[1] - pb[1]
+	switch pb[0] - pa[0] {
+	case 0:
+		return Abs(columns) == 1
+	case 1:
+		return columns == 0 || columns == 1
+	}
+	return false
+}
+
+// KeyboardDistance returns the edit distance between two strings where typos
+// are cheaper: substituting a letter by a key next to it and swapping two
+// adjacent letters cost half as much as other edits. Case is ignored.
+func KeyboardDistance(a, b string) float64 {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+	// Three rows are enough for the transpositions.
+	rows := [3][]float64{}
+	for i := range rows {
+		rows[i] = make([]float64, len(rb)+1)
+	}
+	for j := range rows[0] {
+		rows[0][j] = float64(j)
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev2, prev, row := rows[(i+1)%3], rows[(i+2)%3], rows[i%3]
+		row[0] = float64(i)
+		for j := 1; j <= len(rb); j++ {
+			cost := 1.0
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			} else if adjacentKeys(ra[i-1], rb[j-1]) {
+				cost = 0.5
+			}
+			row[j] = math.Min(math.Min(prev[j], row[j-1])+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				row[j] = math.Min(row[j], prev2[j-2]+0.5)
+			}
+		}
+	}
+	return rows[len(ra)%3][len(rb)]
+}